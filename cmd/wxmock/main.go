@@ -0,0 +1,21 @@
+// wxmock 在本地运行微信接口模拟服务，将 wechat.api_base 配置为其地址即可离线调试小程序登录。
+// 小程序端传入 mock_ 开头的任意 code（如 mock_alice）即可登录为固定的模拟用户；
+// GET /mock/phonenumber?code=mock_alice&phone=13800138000 返回该用户绑定手机号所需的 encryptedData 与 iv。
+package main
+
+import (
+	"flag"
+	"log"
+	"mio/gin-example/wechat/wechattest"
+	"net/http"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:9100", "监听地址")
+	appID := flag.String("appid", "wx-mock-appid", "小程序 AppID")
+	secret := flag.String("secret", "wx-mock-secret", "小程序 AppSecret")
+	flag.Parse()
+
+	log.Printf("微信接口模拟服务监听 %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, wechattest.NewServer(*appID, *secret)))
+}
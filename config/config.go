@@ -0,0 +1,160 @@
+// Package config 加载服务配置。配置文件为 JSON 格式，路径由环境变量 CONFIG_FILE 指定，
+// 默认读取工作目录下的 config.json；文件不存在时使用默认值。
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+)
+
+type Config struct {
+	Video   VideoConfig   `json:"video"`
+	Storage StorageConfig `json:"storage"`
+	Upload  UploadConfig  `json:"upload"`
+	Cover   CoverConfig   `json:"cover"`
+	WeChat  WeChatConfig  `json:"wechat"`
+	SMS     SMSConfig     `json:"sms"`
+	Auth    AuthConfig    `json:"auth"`
+}
+
+// VideoConfig 视频观看进度相关配置
+type VideoConfig struct {
+	CompleteThreshold float64 `json:"complete_threshold"` // 观看进度达到该百分比即视为看完
+	MaxPlaybackRate   float64 `json:"max_playback_rate"`  // 允许的最大播放倍速，用于识别拖动进度条
+	JumpTolerance     float64 `json:"jump_tolerance"`     // 进度校验容差（秒），吸收网络延迟与心跳抖动
+	LinkSecret        string  `json:"link_secret"`        // 播放链接签名密钥，为空时每次启动随机生成
+	LinkTTL           int     `json:"link_ttl"`           // 播放链接有效期（秒）
+}
+
+// StorageConfig 文件存储配置
+type StorageConfig struct {
+	Driver  string   `json:"driver"`   // 存储后端：local/s3
+	Dir     string   `json:"dir"`      // 本地存储目录
+	BaseURL string   `json:"base_url"` // 本地文件访问路径前缀
+	S3      S3Config `json:"s3"`
+}
+
+// S3Config S3 兼容对象存储配置（如 MinIO、各云厂商对象存储）
+type S3Config struct {
+	Endpoint  string `json:"endpoint"` // 服务地址，如 https://s3.cn-north-1.amazonaws.com.cn
+	Region    string `json:"region"`
+	Bucket    string `json:"bucket"`
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+	PublicURL string `json:"public_url"` // 文件对外访问地址前缀，为空时使用服务地址
+	PathStyle bool   `json:"path_style"` // 使用路径风格地址（MinIO 需要开启）
+}
+
+// UploadConfig 视频上传配置
+type UploadConfig struct {
+	TempDir   string `json:"temp_dir"`   // 分片上传的暂存目录，不应位于对外提供访问的目录下
+	ChunkSize int64  `json:"chunk_size"` // 单个分片的最大字节数
+	MaxSize   int64  `json:"max_size"`   // 视频文件的最大字节数
+}
+
+// CoverConfig 课程默认封面配置
+type CoverConfig struct {
+	FontFile string `json:"font_file"` // 额外的字体文件，用于绘制未嵌入字体中没有的字符
+}
+
+// WeChatConfig 小程序配置
+type WeChatConfig struct {
+	AppID   string `json:"app_id"`
+	Secret  string `json:"secret"`
+	APIBase string `json:"api_base"` // 微信接口地址，离线调试时可指向 cmd/wxmock 启动的模拟服务
+}
+
+// SMSConfig 短信验证码配置
+type SMSConfig struct {
+	Store       string `json:"store"`        // 验证码存储：memory/sql，多实例部署时使用 sql
+	Sender      string `json:"sender"`       // 发送渠道：log 写入日志，file 写入 SenderFile，均只用于开发环境
+	SenderFile  string `json:"sender_file"`  // file 渠道写入的文件
+	CodeLength  int    `json:"code_length"`  // 验证码位数
+	TTL         int    `json:"ttl"`          // 验证码有效期（秒）
+	Cooldown    int    `json:"cooldown"`     // 同一手机号两次发送的最小间隔（秒）
+	MaxAttempts int    `json:"max_attempts"` // 允许的错误次数，达到后验证码作废
+}
+
+// AuthConfig 登录令牌配置
+type AuthConfig struct {
+	AccessTTL  int            `json:"access_ttl"`  // 访问令牌有效期（秒）
+	RefreshTTL int            `json:"refresh_ttl"` // 刷新令牌有效期（秒），每次刷新后顺延，超过该时长未使用需重新登录
+	ActiveKey  string         `json:"active_key"`  // 签发令牌使用的密钥 kid
+	Keys       []JWTKeyConfig `json:"keys"`        // 签名密钥，为空时每次启动随机生成 HS256 密钥
+	Issuer     string         `json:"issuer"`      // 令牌的 iss，其他服务通过 JWKS 验证令牌时据此确认签发方
+	Audience   string         `json:"audience"`    // 令牌的 aud，只接受 aud 一致的令牌
+}
+
+// JWTKeyConfig 令牌签名密钥。轮换时新增密钥并设为 active_key，
+// 旧密钥设置 verify_until 后继续验证已签发的令牌，到期后即可从配置中移除
+type JWTKeyConfig struct {
+	ID             string     `json:"kid"`
+	Algorithm      string     `json:"alg"`              // HS256/RS256/EdDSA
+	Secret         string     `json:"secret"`           // HS256 密钥，至少 32 字节
+	PrivateKeyFile string     `json:"private_key_file"` // RS256/EdDSA 私钥 PEM 文件
+	PublicKeyFile  string     `json:"public_key_file"`  // RS256/EdDSA 公钥 PEM 文件，只用于验证的旧密钥可只配置公钥
+	VerifyUntil    *time.Time `json:"verify_until"`     // 停止验证的时间，应晚于该密钥签发的最后一个访问令牌的过期时间
+}
+
+// Default 返回默认配置
+func Default() *Config {
+	return &Config{
+		Video: VideoConfig{
+			CompleteThreshold: 95,
+			MaxPlaybackRate:   2,
+			JumpTolerance:     5,
+			LinkTTL:           1800,
+		},
+		Storage: StorageConfig{
+			Driver:  "local",
+			Dir:     "uploads",
+			BaseURL: "/files",
+		},
+		Upload: UploadConfig{
+			TempDir:   "upload_tmp",
+			ChunkSize: 8 << 20,
+			MaxSize:   2 << 30,
+		},
+		WeChat: WeChatConfig{
+			APIBase: "https://api.weixin.qq.com",
+		},
+		SMS: SMSConfig{
+			Store:       "memory",
+			Sender:      "log",
+			SenderFile:  "sms_codes.log",
+			CodeLength:  6,
+			TTL:         300,
+			Cooldown:    60,
+			MaxAttempts: 5,
+		},
+		Auth: AuthConfig{
+			AccessTTL:  900,
+			RefreshTTL: 30 * 24 * 3600,
+			Issuer:     "gin-example",
+			Audience:   "gin-example-api",
+		},
+	}
+}
+
+// Load 读取配置文件，未配置的字段保留默认值
+func Load() (*Config, error) {
+	cfg := Default()
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		path = "config.json"
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
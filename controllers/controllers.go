@@ -1,21 +1,59 @@
 package controllers
 
 import (
+	"context"
+	"mio/gin-example/config"
+	"mio/gin-example/cover"
+	"mio/gin-example/jwtkeys"
 	"mio/gin-example/models"
-	"net/http"
+	"mio/gin-example/storage"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
-	log "github.com/sirupsen/logrus"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
 
+// Conf 服务配置，由 main 在启动时加载
+var Conf = config.Default()
+
+// Store 文件存储，由 main 在启动时初始化
+var Store storage.Storage
+
+// Keys 登录令牌签名密钥，由 main 在启动时初始化
+var Keys *jwtkeys.Manager
+
+// Covers 课程默认封面生成器，为空时不生成封面
+var Covers *cover.Generator
+
+// courseService 创建课程服务，配置了封面生成器时新建课程自动生成默认封面
+func courseService() *models.CourseService {
+	service := models.NewCourseService(DB)
+	if Covers != nil {
+		service.WithCoverGenerator(func(name string) (string, error) {
+			return Covers.Generate(context.Background(), name)
+		})
+	}
+	return service
+}
+
+// 中间件写入上下文的键
+const (
+	UserIDKey    = "user_id"
+	RoleKey      = "role"
+	SessionIDKey = "session_id"
+)
+
+// currentUserID 返回当前登录用户ID
+func currentUserID(c *gin.Context) uint {
+	return c.GetUint(UserIDKey)
+}
+
 // func Signup(c *gin.Context) {
 // 	type User struct {
 // 		Name     string `form:"username" binding:"required,min=3,max=10"`
@@ -51,59 +89,6 @@ var DB *gorm.DB
 // 	models.CreateUser(DB, user.Name, user.Email, string(hash), user.Company)
 // }
 
-func Login(c *gin.Context) {
-	type User struct {
-		Email    string `form:"email" binding:"required,email" gorm:"unique"`
-		Password string `form:"password" binding:"required,password"`
-	}
-	var user User
-
-	if err := c.ShouldBind(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err,
-		})
-		log.Errorln(err)
-		return
-	}
-
-	userInDB := models.FindUSerByEmail(DB, user.Email)
-	if userInDB.ID == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "no this user",
-		})
-		log.Errorln("no this user: ", user.Email)
-		return
-	}
-
-	err := bcrypt.CompareHashAndPassword([]byte(userInDB.Password), []byte(user.Password))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err,
-		})
-		log.Errorln(err)
-		return
-	}
-
-	t, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": userInDB.ID,
-		// "pwd": userInDB.Password,
-		"exp": time.Now().Add(time.Hour * 24).Unix(),
-		"rol": userInDB.Role,
-		"ver": userInDB.TokenVersion + 1,
-	}).SignedString([]byte("ygredgds"))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err,
-		})
-		log.Errorln(err)
-		return
-	}
-	models.Refresh(DB, userInDB.ID, userInDB.TokenVersion+1)
-	c.JSON(http.StatusOK, gin.H{
-		"token": t,
-	})
-}
-
 func trimRequestFields(req interface{}) {
 	val := reflect.ValueOf(req).Elem()
 
@@ -127,21 +112,16 @@ func trimRequestFields(req interface{}) {
 	}
 }
 
-func generateJWT(user models.User) (any, any) {
-	userInDB := models.FindUSerByEmail(DB, user.Email)
-	if userInDB.ID == 0 {
-		return nil, "no this user"
-	}
-	t, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": userInDB.ID,
-		// "pwd": userInDB.Password,
-		"exp": time.Now().Add(time.Hour * 24).Unix(),
-		"rol": userInDB.Role,
-		"ver": userInDB.TokenVersion + 1,
-	}).SignedString([]byte("ygredgds"))
-	if err != nil {
-		return nil, err
-	}
-	models.Refresh(DB, userInDB.ID, userInDB.TokenVersion+1)
-	return t, nil
+// generateJWT 签发访问令牌。令牌绑定登录会话，会话被吊销或用户令牌版本提升后立即失效。
+// sub 按 JWT 规范使用字符串形式的用户ID
+func generateJWT(user *models.User, sessionID string) (string, error) {
+	return Keys.Sign(jwt.MapClaims{
+		"iss": Conf.Auth.Issuer,
+		"aud": Conf.Auth.Audience,
+		"sub": strconv.FormatUint(uint64(user.ID), 10),
+		"exp": time.Now().Add(time.Duration(Conf.Auth.AccessTTL) * time.Second).Unix(),
+		"rol": user.Role,
+		"ver": user.TokenVersion,
+		"sid": sessionID,
+	})
 }
@@ -1,71 +1,75 @@
 package controllers
 
 import (
+	"errors"
 	"mio/gin-example/models"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
+	log "github.com/sirupsen/logrus"
 )
 
-func CreateCourse(c *gin.Context) {
-	type UnitInput struct {
-		UnitName string `json:"unit_name" binding:"required"`
-		Position int    `json:"position"`
-	}
+// CourseUnitInput 创建课程时一并创建的单元
+type CourseUnitInput struct {
+	UnitName    string `json:"unit_name" binding:"required,max=100"`
+	Description string `json:"description"`
+	Position    int    `json:"position"`
+}
 
-	type CourseInput struct {
-		Name           string      `json:"name" binding:"required"`
-		Description    *string     `json:"description"`
-		CoverImage     *string     `json:"cover_image"`
-		Units          []UnitInput `json:"units"`
-		EnrollmentCode string      `json:"enrollment_code" binding:"required"`
-		IsOpen         bool        `json:"is_open"`
-	}
+// CreateCourseRequest 创建课程请求体
+type CreateCourseRequest struct {
+	Name           string            `json:"name" binding:"required,max=100"`
+	Description    string            `json:"description"`
+	CoverImage     string            `json:"cover_image" binding:"max=255"`
+	Units          []CourseUnitInput `json:"units" binding:"dive"`
+	EnrollmentCode string            `json:"enrollment_code" binding:"required,max=50"`
+	IsOpen         bool              `json:"is_open"`
+	MaxEnrollments uint              `json:"max_enrollments"`
+	EnrollStartAt  *time.Time        `json:"enroll_start_at"`
+	EnrollEndAt    *time.Time        `json:"enroll_end_at"`
+}
 
-	var input CourseInput
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
+// UpdateCourseRequest 修改课程请求体，未传的字段保持不变
+type UpdateCourseRequest struct {
+	Name           *string    `json:"name" binding:"omitempty,max=100"`
+	Description    *string    `json:"description"`
+	CoverImage     *string    `json:"cover_image" binding:"omitempty,max=255"`
+	EnrollmentCode *string    `json:"enrollment_code" binding:"omitempty,max=50"`
+	IsOpen         *bool      `json:"is_open"`
+	MaxEnrollments *uint      `json:"max_enrollments"`
+	EnrollStartAt  *time.Time `json:"enroll_start_at"`
+	EnrollEndAt    *time.Time `json:"enroll_end_at"`
+}
 
-	// 检查课程名称唯一性
-	var existingCourse models.Course
-	if result := DB.Where("name = ?", input.Name).First(&existingCourse); result.RowsAffected > 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "课程名称已存在"})
+func CreateCourse(c *gin.Context) {
+	var req CreateCourseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 转换输入到模型
 	course := models.Course{
-		Name: input.Name,
-		// Description:    input.Description,
-		// CoverImage:     input.CoverImage,
-		EnrollmentCode: input.EnrollmentCode,
-		IsOpen:         input.IsOpen,
-	}
-
-	// 转换单元
-	var units []models.CourseUnit
-	for _, u := range input.Units {
-		units = append(units, models.CourseUnit{
-			UnitName: u.UnitName,
-			Order:    u.Position,
+		Name:           req.Name,
+		Description:    req.Description,
+		CoverImage:     req.CoverImage,
+		EnrollmentCode: req.EnrollmentCode,
+		IsOpen:         req.IsOpen,
+		MaxEnrollments: req.MaxEnrollments,
+		EnrollStartAt:  req.EnrollStartAt,
+		EnrollEndAt:    req.EnrollEndAt,
+	}
+	for _, u := range req.Units {
+		course.Units = append(course.Units, models.CourseUnit{
+			UnitName:    u.UnitName,
+			Description: u.Description,
+			Order:       u.Position,
 		})
 	}
-	course.Units = units
 
-	// 使用事务创建
-	err := DB.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Create(&course).Error; err != nil {
-			return err
-		}
-		return nil
-	})
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建课程失败"})
+	if err := courseService().CreateCourse(&course); err != nil {
+		handleCourseError(c, err)
 		return
 	}
 
@@ -79,99 +83,174 @@ func GetCourse(c *gin.Context) {
 		return
 	}
 
-	var course models.Course
-	result := DB.Preload("Units", func(db *gorm.DB) *gorm.DB {
-		return db.Order("position ASC")
-	}).First(&course, id)
-
-	if result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "课程不存在"})
+	course, err := courseService().GetCourse(uint(id))
+	if err != nil {
+		handleCourseError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, course)
 }
 
-func UpdateCourse(c *gin.Context) {
+// PublicCourse 未登录即可查看的课程信息，不含报名码
+type PublicCourse struct {
+	ID              uint                `json:"id"`
+	Name            string              `json:"name"`
+	Description     string              `json:"description"`
+	CoverImage      string              `json:"cover_image"`
+	Units           []models.CourseUnit `json:"units"`
+	IsOpen          bool                `json:"is_open"`
+	EnrollmentCount uint                `json:"enrollment_count"`
+	CompletionCount uint                `json:"completion_count"`
+	MaxEnrollments  uint                `json:"max_enrollments"`
+	EnrollStartAt   *time.Time          `json:"enroll_start_at,omitempty"`
+	EnrollEndAt     *time.Time          `json:"enroll_end_at,omitempty"`
+}
+
+// GetPublicCourse 公开的课程详情，报名码只在管理端返回
+func GetPublicCourse(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的课程ID"})
 		return
 	}
 
-	type UpdateInput struct {
-		Name           *string `json:"name"`
-		Description    *string `json:"description"`
-		CoverImage     *string `json:"cover_image"`
-		EnrollmentCode *string `json:"enrollment_code"`
-		IsOpen         *bool   `json:"is_open"`
+	course, err := courseService().GetCourse(uint(id))
+	if err != nil {
+		handleCourseError(c, err)
+		return
 	}
 
-	var input UpdateInput
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	c.JSON(http.StatusOK, PublicCourse{
+		ID:              course.ID,
+		Name:            course.Name,
+		Description:     course.Description,
+		CoverImage:      course.CoverImage,
+		Units:           course.Units,
+		IsOpen:          course.IsOpen,
+		EnrollmentCount: course.EnrollmentCount,
+		CompletionCount: course.CompletionCount,
+		MaxEnrollments:  course.MaxEnrollments,
+		EnrollStartAt:   course.EnrollStartAt,
+		EnrollEndAt:     course.EnrollEndAt,
+	})
+}
+
+func UpdateCourse(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的课程ID"})
 		return
 	}
 
-	var course models.Course
-	if result := DB.First(&course, id); result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "课程不存在"})
+	var req UpdateCourseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 更新字段
-	if input.Name != nil {
-		// 检查名称唯一性
-		var existing models.Course
-		if DB.Where("name = ? AND id != ?", *input.Name, id).First(&existing).RowsAffected > 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "课程名称已存在"})
-			return
-		}
-		course.Name = *input.Name
+	course, err := courseService().UpdateCourse(uint(id), models.CourseUpdate{
+		Name:           req.Name,
+		Description:    req.Description,
+		CoverImage:     req.CoverImage,
+		EnrollmentCode: req.EnrollmentCode,
+		IsOpen:         req.IsOpen,
+		MaxEnrollments: req.MaxEnrollments,
+		EnrollStartAt:  req.EnrollStartAt,
+		EnrollEndAt:    req.EnrollEndAt,
+	})
+	if err != nil {
+		handleCourseError(c, err)
+		return
 	}
 
-	// if input.Description != nil {
-	// 	course.Description = input.Description
-	// }
+	c.JSON(http.StatusOK, course)
+}
 
-	// if input.CoverImage != nil {
-	// 	course.CoverImage = input.CoverImage
-	// }
+// DeleteCourse 删除课程及其单元、视频、学员记录与课程考试；?dry_run=true 时只返回影响范围
+func DeleteCourse(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的课程ID"})
+		return
+	}
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
 
-	if input.EnrollmentCode != nil {
-		course.EnrollmentCode = *input.EnrollmentCode
+	report, err := courseService().DeleteCourse(uint(id), dryRun)
+	if err != nil {
+		handleCourseError(c, err)
+		return
 	}
 
-	if input.IsOpen != nil {
-		course.IsOpen = *input.IsOpen
+	c.JSON(http.StatusOK, report)
+}
+
+// RestoreCourse 恢复已删除的课程及随之删除的记录
+func RestoreCourse(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的课程ID"})
+		return
 	}
 
-	if err := DB.Save(&course).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新课程失败"})
+	report, err := courseService().RestoreCourse(uint(id))
+	if err != nil {
+		handleCourseError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, course)
+	c.JSON(http.StatusOK, report)
 }
 
-// todo：删除时需要删除移除视频、题库、参与学习的用户的信息等全部信息
-func DeleteCourse(c *gin.Context) {
+// RegenerateCourseCover 按课程当前名称重新生成默认封面
+func RegenerateCourseCover(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的课程ID"})
 		return
 	}
 
-	result := DB.Delete(&models.Course{}, id)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除课程失败"})
+	course, err := courseService().RegenerateCover(uint(id))
+	if err != nil {
+		handleCourseError(c, err)
 		return
 	}
 
-	if result.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "课程不存在"})
-		return
-	}
+	c.JSON(http.StatusOK, gin.H{"cover_image": course.CoverImage})
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "课程删除成功"})
+// handleCourseError 将课程领域错误映射为HTTP状态码，管理端与学员端共用
+func handleCourseError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, models.ErrCourseNotFound),
+		errors.Is(err, models.ErrUnitNotFound),
+		errors.Is(err, models.ErrVideoNotFound),
+		errors.Is(err, models.ErrSubtitleNotFound),
+		errors.Is(err, models.ErrInvalidEnrollmentCode):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrInvalidCourse),
+		errors.Is(err, models.ErrInvalidUnitOrder),
+		errors.Is(err, models.ErrVideoNotInCourse),
+		errors.Is(err, models.ErrInvalidVideo),
+		errors.Is(err, models.ErrInvalidSubtitle),
+		errors.Is(err, models.ErrInvalidChapter):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrCourseClosed),
+		errors.Is(err, models.ErrEnrollmentNotStarted),
+		errors.Is(err, models.ErrEnrollmentEnded):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrCourseNameExists),
+		errors.Is(err, models.ErrEnrollmentCodeExists),
+		errors.Is(err, models.ErrAlreadyEnrolled),
+		errors.Is(err, models.ErrCourseFull),
+		errors.Is(err, models.ErrCourseInUse),
+		errors.Is(err, models.ErrVideoTitleExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case isUniqueViolation(err):
+		// 并发写入时由唯一索引兜底
+		c.JSON(http.StatusConflict, gin.H{"error": "课程信息与已有记录冲突"})
+	default:
+		log.Error("课程操作失败: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "课程操作失败"})
+	}
 }
@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"mio/gin-example/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UnitRequest 单元请求体
+type UnitRequest struct {
+	UnitName    string `json:"unit_name" binding:"required,max=100"`
+	Description string `json:"description"`
+}
+
+// UpdateUnitRequest 修改单元请求体
+type UpdateUnitRequest struct {
+	UnitName    *string `json:"unit_name" binding:"omitempty,max=100"`
+	Description *string `json:"description"`
+}
+
+// ReorderUnitsRequest 单元排序请求体，按新顺序列出课程的全部单元
+type ReorderUnitsRequest struct {
+	UnitIDs []uint `json:"unit_ids" binding:"required,min=1"`
+}
+
+// MoveVideosRequest 批量移动视频请求体，unit_id 为 null 表示移出单元
+type MoveVideosRequest struct {
+	VideoIDs []uint `json:"video_ids" binding:"required,min=1"`
+	UnitID   *uint  `json:"unit_id"`
+}
+
+// GetCourseUnits 获取课程单元列表
+func GetCourseUnits(c *gin.Context) {
+	courseID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的课程ID"})
+		return
+	}
+
+	units, err := courseService().ListUnits(uint(courseID))
+	if err != nil {
+		handleCourseError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, units)
+}
+
+// CreateCourseUnit 在课程末尾添加单元
+func CreateCourseUnit(c *gin.Context) {
+	courseID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的课程ID"})
+		return
+	}
+
+	var req UnitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.UnitName = strings.TrimSpace(req.UnitName)
+	if req.UnitName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "单元名称不能为空"})
+		return
+	}
+
+	unit := models.CourseUnit{UnitName: req.UnitName, Description: req.Description}
+	if err := courseService().AddUnit(uint(courseID), &unit); err != nil {
+		handleCourseError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, unit)
+}
+
+// UpdateCourseUnit 修改单元名称与描述
+func UpdateCourseUnit(c *gin.Context) {
+	unitID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的单元ID"})
+		return
+	}
+
+	var req UpdateUnitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := make(map[string]any)
+	if req.UnitName != nil {
+		name := strings.TrimSpace(*req.UnitName)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "单元名称不能为空"})
+			return
+		}
+		updates["unit_name"] = name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+
+	unit, err := courseService().UpdateUnit(uint(unitID), updates)
+	if err != nil {
+		handleCourseError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, unit)
+}
+
+// DeleteCourseUnit 删除单元，?move_to=<单元ID> 指定单元内视频的去向，缺省时视频移出单元
+func DeleteCourseUnit(c *gin.Context) {
+	unitID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的单元ID"})
+		return
+	}
+
+	var moveTo *uint
+	if v := c.Query("move_to"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的目标单元ID"})
+			return
+		}
+		target := uint(id)
+		moveTo = &target
+	}
+
+	if err := courseService().DeleteUnit(uint(unitID), moveTo); err != nil {
+		handleCourseError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "单元已删除"})
+}
+
+// ReorderCourseUnits 调整课程单元顺序
+func ReorderCourseUnits(c *gin.Context) {
+	courseID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的课程ID"})
+		return
+	}
+
+	var req ReorderUnitsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := courseService()
+	if err := service.ReorderUnits(uint(courseID), req.UnitIDs); err != nil {
+		handleCourseError(c, err)
+		return
+	}
+	units, err := service.ListUnits(uint(courseID))
+	if err != nil {
+		handleCourseError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, units)
+}
+
+// MoveCourseVideos 批量调整视频所属单元
+func MoveCourseVideos(c *gin.Context) {
+	courseID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的课程ID"})
+		return
+	}
+
+	var req MoveVideosRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := courseService().MoveVideos(uint(courseID), req.VideoIDs, req.UnitID); err != nil {
+		handleCourseError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "视频已移动"})
+}
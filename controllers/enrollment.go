@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EnrollRequest 报名请求体
+type EnrollRequest struct {
+	Code string `json:"code" binding:"required,max=50"`
+}
+
+// EnrollCourse 学员凭报名码报名课程
+func EnrollCourse(c *gin.Context) {
+	var req EnrollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	enrollment, err := courseService().Enroll(currentUserID(c), req.Code)
+	if err != nil {
+		handleCourseError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"enrollment_id": enrollment.ID,
+		"course_id":     enrollment.CourseID,
+		"course_name":   enrollment.Course.Name,
+		"enrolled_at":   enrollment.EnrolledAt,
+	})
+}
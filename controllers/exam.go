@@ -0,0 +1,253 @@
+package controllers
+
+import (
+	"errors"
+	"math"
+	"mio/gin-example/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// ExamRequest 创建/更新考试请求体，包含完整的试题配置、前置条件和分数规则
+type ExamRequest struct {
+	Name                  string    `json:"name" binding:"required"`
+	Description           *string   `json:"description"`
+	StartTime             time.Time `json:"start_time" binding:"required"`
+	EndTime               time.Time `json:"end_time" binding:"required"`
+	Duration              int       `json:"duration" binding:"required"`
+	MaxAttempts           *int      `json:"max_attempts"`
+	PassingScore          *int      `json:"passing_score"`
+	BelongsType           string    `json:"belongs_type" binding:"required"`
+	BelongsID             uint      `json:"belongs_id" binding:"required"`
+	MultipleChoiceScoring string    `json:"multiple_choice_scoring"`
+	BlankNormalize        string    `json:"blank_normalize"`
+	ReviewPolicy          string    `json:"review_policy"`
+
+	QuestionConfigs []struct {
+		QuestionBankID uint   `json:"question_bank_id" binding:"required"`
+		QuestionType   string `json:"question_type"`
+		Amount         int    `json:"amount" binding:"required"`
+		Difficulty     *int   `json:"difficulty"`
+	} `json:"question_configs" binding:"required,dive"`
+
+	Prerequisites []struct {
+		CourseID    uint `json:"course_id" binding:"required"`
+		MinProgress *int `json:"min_progress"`
+	} `json:"prerequisites" binding:"dive"`
+
+	ScoreRules []struct {
+		RuleType         string  `json:"rule_type" binding:"required"`
+		TargetID         uint    `json:"target_id"`
+		TargetType       string  `json:"target_type"`
+		ScorePerQuestion float64 `json:"score_per_question"`
+	} `json:"score_rules" binding:"dive"`
+}
+
+// toModel 转换为考试模型，未填写的字段使用模型默认值
+func (r *ExamRequest) toModel() *models.Exam {
+	exam := &models.Exam{
+		Name:                  r.Name,
+		Description:           r.Description,
+		StartTime:             r.StartTime,
+		EndTime:               r.EndTime,
+		Duration:              r.Duration,
+		MaxAttempts:           3,
+		PassingScore:          60,
+		BelongsType:           r.BelongsType,
+		BelongsID:             r.BelongsID,
+		MultipleChoiceScoring: r.MultipleChoiceScoring,
+		BlankNormalize:        r.BlankNormalize,
+		ReviewPolicy:          r.ReviewPolicy,
+	}
+	if r.MaxAttempts != nil {
+		exam.MaxAttempts = *r.MaxAttempts
+	}
+	if r.PassingScore != nil {
+		exam.PassingScore = *r.PassingScore
+	}
+	if exam.MultipleChoiceScoring == "" {
+		exam.MultipleChoiceScoring = models.ScoringAllOrNothing
+	}
+	if exam.BlankNormalize == "" {
+		exam.BlankNormalize = "case,space,width"
+	}
+	if exam.ReviewPolicy == "" {
+		exam.ReviewPolicy = models.ReviewAfterClose
+	}
+
+	for _, cfg := range r.QuestionConfigs {
+		exam.QuestionConfigs = append(exam.QuestionConfigs, models.ExamQuestionConfig{
+			QuestionBankID: cfg.QuestionBankID,
+			QuestionType:   cfg.QuestionType,
+			Amount:         cfg.Amount,
+			Difficulty:     cfg.Difficulty,
+		})
+	}
+	for _, p := range r.Prerequisites {
+		prerequisite := models.ExamPrerequisite{CourseID: p.CourseID, MinProgress: 100}
+		if p.MinProgress != nil {
+			prerequisite.MinProgress = *p.MinProgress
+		}
+		exam.Prerequisites = append(exam.Prerequisites, prerequisite)
+	}
+	for _, rule := range r.ScoreRules {
+		exam.ScoreRules = append(exam.ScoreRules, models.ExamScoreRule{
+			RuleType:         rule.RuleType,
+			TargetID:         rule.TargetID,
+			TargetType:       rule.TargetType,
+			ScorePerQuestion: rule.ScorePerQuestion,
+		})
+	}
+	return exam
+}
+
+// CreateExam 创建考试
+func CreateExam(c *gin.Context) {
+	var req ExamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	trimRequestFields(&req)
+
+	exam := req.toModel()
+	if err := models.NewExamService(DB).CreateExam(exam); err != nil {
+		handleExamManageError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, exam)
+}
+
+// GetExams 获取考试列表
+func GetExams(c *gin.Context) {
+	var query struct {
+		Name        string `form:"name"`
+		BelongsType string `form:"belongs_type"`
+		BelongsID   uint   `form:"belongs_id"`
+		Page        int    `form:"page,default=1"`
+		Limit       int    `form:"limit,default=20"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.Limit < 1 || query.Limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit value (1-100)"})
+		return
+	}
+
+	db := DB.Model(&models.Exam{})
+	if query.Name != "" {
+		db = db.Where("name LIKE ?", "%"+query.Name+"%")
+	}
+	if query.BelongsType != "" {
+		db = db.Where("belongs_type = ?", query.BelongsType)
+	}
+	if query.BelongsID > 0 {
+		db = db.Where("belongs_id = ?", query.BelongsID)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "数据库查询失败"})
+		return
+	}
+
+	var exams []models.Exam
+	offset := (query.Page - 1) * query.Limit
+	if err := db.Order("start_time desc").Offset(offset).Limit(query.Limit).Find(&exams).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "数据库查询失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": exams,
+		"pagination": gin.H{
+			"page":  query.Page,
+			"limit": query.Limit,
+			"total": total,
+			"pages": int(math.Ceil(float64(total) / float64(query.Limit))),
+		},
+	})
+}
+
+// GetExam 获取考试及其完整配置
+func GetExam(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的考试ID"})
+		return
+	}
+
+	exam, err := models.NewExamService(DB).GetExam(uint(id))
+	if err != nil {
+		handleExamManageError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, exam)
+}
+
+// UpdateExam 以完整文档替换考试配置
+func UpdateExam(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的考试ID"})
+		return
+	}
+
+	var req ExamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	trimRequestFields(&req)
+
+	exam, err := models.NewExamService(DB).UpdateExam(uint(id), req.toModel())
+	if err != nil {
+		handleExamManageError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, exam)
+}
+
+// DeleteExam 删除考试
+func DeleteExam(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的考试ID"})
+		return
+	}
+
+	if err := models.NewExamService(DB).DeleteExam(uint(id)); err != nil {
+		handleExamManageError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "考试删除成功"})
+}
+
+func handleExamManageError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, models.ErrInvalidExam):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrExamInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case isUniqueViolation(err):
+		c.JSON(http.StatusConflict, gin.H{"error": "考试名称已存在"})
+	case errors.Is(err, models.ErrExamNotFound), errors.Is(err, models.ErrInsufficientQuestions):
+		handleExamError(c, err)
+	default:
+		log.Errorln(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "考试保存失败"})
+	}
+}
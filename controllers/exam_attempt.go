@@ -0,0 +1,329 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"mio/gin-example/dto"
+	"mio/gin-example/grading"
+	"mio/gin-example/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// StartExamAttempt 开始考试
+func StartExamAttempt(c *gin.Context) {
+	examID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的考试ID"})
+		return
+	}
+
+	service := examService()
+	attempt, err := service.StartAttempt(currentUserID(c), uint(examID))
+	if err != nil {
+		handleExamError(c, err)
+		return
+	}
+	// 继续未完成的作答时需要带上已保存的答案
+	if attempt, err = service.GetAttempt(attempt.UserID, attempt.ID); err != nil {
+		handleExamError(c, err)
+		return
+	}
+
+	paper, err := learnerPaper(attempt)
+	if err != nil {
+		handleExamError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, paper)
+}
+
+// GetExamAttempt 获取答题记录；作答中返回试卷及已保存的作答，交卷后只返回概要
+func GetExamAttempt(c *gin.Context) {
+	attemptID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的答题记录ID"})
+		return
+	}
+
+	attempt, err := examService().GetAttempt(currentUserID(c), uint(attemptID))
+	if err != nil {
+		handleExamError(c, err)
+		return
+	}
+
+	if attempt.Status != models.AttemptStatusInProgress {
+		summary, err := learnerAttempt(attempt)
+		if err != nil {
+			handleExamError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, summary)
+		return
+	}
+
+	paper, err := learnerPaper(attempt)
+	if err != nil {
+		handleExamError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, paper)
+}
+
+// GetAttemptReview 交卷后查看答案与解析，是否可查看由考试的公开策略决定
+func GetAttemptReview(c *gin.Context) {
+	attemptID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的答题记录ID"})
+		return
+	}
+
+	attempt, err := examService().GetAttempt(currentUserID(c), uint(attemptID))
+	if err != nil {
+		handleExamError(c, err)
+		return
+	}
+	if attempt.Status == models.AttemptStatusInProgress {
+		c.JSON(http.StatusForbidden, gin.H{"error": "交卷后才能查看答案"})
+		return
+	}
+
+	var exam models.Exam
+	if err := DB.Unscoped().First(&exam, attempt.ExamID).Error; err != nil {
+		handleExamError(c, err)
+		return
+	}
+	// 分值与判分时一致，按开考时生效的分数规则计算
+	rules, err := models.ScoreRulesAt(DB, attempt.ExamID, attempt.StartTime)
+	if err != nil {
+		handleExamError(c, err)
+		return
+	}
+
+	now := time.Now()
+	availableAt := exam.ReviewAvailableAt(attempt)
+	if availableAt == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "本场考试不公开答案"})
+		return
+	}
+	if now.Before(*availableAt) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":               "考试结束后才能查看答案",
+			"review_available_at": availableAt,
+		})
+		return
+	}
+
+	questions, err := paperQuestions(attempt)
+	if err != nil {
+		handleExamError(c, err)
+		return
+	}
+	answers := make(map[uint]*models.ExamAnswer, len(attempt.Answers))
+	for i := range attempt.Answers {
+		answers[attempt.Answers[i].QuestionID] = &attempt.Answers[i]
+	}
+
+	review := dto.ReviewPaper{
+		LearnerAttempt: dto.NewLearnerAttempt(attempt, &exam, now),
+		Questions:      make([]dto.ReviewQuestion, 0, len(attempt.Paper)),
+	}
+	for _, item := range attempt.Paper {
+		q, ok := questions[item.QuestionID]
+		if !ok {
+			continue
+		}
+		points := grading.PointsFor(q, rules)
+		review.Questions = append(review.Questions, dto.NewReviewQuestion(q, item, points, answers[q.ID]))
+	}
+
+	c.JSON(http.StatusOK, review)
+}
+
+// SaveExamAnswersRequest 保存作答请求体
+type SaveExamAnswersRequest struct {
+	Answers []models.AnswerInput `json:"answers" binding:"required,dive"`
+}
+
+// SaveExamAnswers 自动保存作答
+func SaveExamAnswers(c *gin.Context) {
+	attemptID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的答题记录ID"})
+		return
+	}
+
+	var req SaveExamAnswersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := examService().SaveAnswers(currentUserID(c), uint(attemptID), req.Answers); err != nil {
+		handleExamError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "作答已保存", "saved_at": time.Now()})
+}
+
+// SubmitExamAttempt 交卷
+func SubmitExamAttempt(c *gin.Context) {
+	attemptID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的答题记录ID"})
+		return
+	}
+
+	attempt, err := examService().SubmitAttempt(currentUserID(c), uint(attemptID))
+	if err != nil {
+		handleExamError(c, err)
+		return
+	}
+
+	summary, err := learnerAttempt(attempt)
+	if err != nil {
+		handleExamError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// GetAttemptPaper 复核答题记录的试卷：按保存的种子重新抽题并与记录比对
+func GetAttemptPaper(c *gin.Context) {
+	attemptID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的答题记录ID"})
+		return
+	}
+
+	attempt, regenerated, err := examService().RegeneratePaper(uint(attemptID))
+	if err != nil {
+		handleExamError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"attempt_id":  attempt.ID,
+		"seed":        attempt.Seed,
+		"start_time":  attempt.StartTime,
+		"paper":       attempt.Paper,
+		"regenerated": regenerated,
+		"consistent":  attempt.Paper.Equal(regenerated),
+	})
+}
+
+// StartAttemptSweeper 定期将超时的答题记录自动交卷
+func StartAttemptSweeper(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			n, err := examService().SweepExpiredAttempts(now)
+			if err != nil {
+				log.Errorf("自动交卷失败: %v", err)
+				continue
+			}
+			if n > 0 {
+				log.Infof("自动交卷 %d 份", n)
+			}
+		}
+	}()
+}
+
+// learnerAttempt 构造面向考生的答题记录概要
+func learnerAttempt(attempt *models.ExamAttempt) (*dto.LearnerAttempt, error) {
+	var exam models.Exam
+	if err := DB.Unscoped().First(&exam, attempt.ExamID).Error; err != nil {
+		return nil, err
+	}
+	view := dto.NewLearnerAttempt(attempt, &exam, time.Now())
+	return &view, nil
+}
+
+// learnerPaper 构造作答中的试卷，题目按抽题顺序排列且不含答案
+func learnerPaper(attempt *models.ExamAttempt) (*dto.LearnerPaper, error) {
+	var exam models.Exam
+	if err := DB.Unscoped().First(&exam, attempt.ExamID).Error; err != nil {
+		return nil, err
+	}
+	rules, err := models.ScoreRulesAt(DB, attempt.ExamID, attempt.StartTime)
+	if err != nil {
+		return nil, err
+	}
+	questions, err := paperQuestions(attempt)
+	if err != nil {
+		return nil, err
+	}
+
+	answers := make(map[uint]json.RawMessage, len(attempt.Answers))
+	for _, a := range attempt.Answers {
+		answers[a.QuestionID] = json.RawMessage(a.UserAnswer)
+	}
+
+	paper := &dto.LearnerPaper{
+		LearnerAttempt: dto.NewLearnerAttempt(attempt, &exam, time.Now()),
+		Questions:      make([]dto.LearnerQuestion, 0, len(attempt.Paper)),
+		Answers:        answers,
+	}
+	for _, item := range attempt.Paper {
+		q, ok := questions[item.QuestionID]
+		if !ok {
+			continue
+		}
+		view := dto.NewLearnerQuestion(q, item, grading.PointsFor(q, rules))
+		_, view.Answered = answers[q.ID]
+		paper.Questions = append(paper.Questions, view)
+	}
+	return paper, nil
+}
+
+// paperQuestions 加载试卷中的题目（含抽题后被删除的题目）
+func paperQuestions(attempt *models.ExamAttempt) (map[uint]*models.Question, error) {
+	var questions []models.Question
+	if err := DB.Unscoped().Where("id IN ?", attempt.Paper.QuestionIDs()).Find(&questions).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[uint]*models.Question, len(questions))
+	for i := range questions {
+		result[questions[i].ID] = &questions[i]
+	}
+	return result, nil
+}
+
+// examService 返回挂载了自动判分的考试服务
+func examService() *models.ExamService {
+	return models.NewExamService(DB).OnFinish(grading.GradeAttempt)
+}
+
+func handleExamError(c *gin.Context, err error) {
+	var prerequisiteErr *models.PrerequisiteError
+	switch {
+	case errors.As(err, &prerequisiteErr):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":               err.Error(),
+			"unmet_prerequisites": prerequisiteErr.Unmet,
+		})
+	case errors.Is(err, models.ErrExamNotFound), errors.Is(err, models.ErrAttemptNotFound),
+		errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrExamNotStarted), errors.Is(err, models.ErrExamClosed),
+		errors.Is(err, models.ErrAttemptExpired), errors.Is(err, models.ErrAttemptSubmitted):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrMaxAttemptsReached), errors.Is(err, models.ErrInsufficientQuestions):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrQuestionNotInExam), errors.Is(err, models.ErrInvalidAnswer):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Errorln(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "考试服务异常"})
+	}
+}
@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"mio/gin-example/grading"
+	"mio/gin-example/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// GradingQueueItem 人工判分队列中的一条主观题作答
+type GradingQueueItem struct {
+	AnswerID    uint       `json:"answer_id"`
+	AttemptID   uint       `json:"attempt_id"`
+	UserID      uint       `json:"user_id"`
+	UserName    string     `json:"user_name"`
+	QuestionID  uint       `json:"question_id"`
+	Content     string     `json:"content"`
+	UserAnswer  string     `json:"user_answer"`
+	Reference   string     `json:"reference"`
+	Analysis    string     `json:"analysis"`
+	MaxScore    float64    `json:"max_score"`
+	Score       float64    `json:"score"`
+	Comment     string     `json:"comment"`
+	SubmittedAt time.Time  `json:"submitted_at"`
+	ReviewedAt  *time.Time `json:"reviewed_at,omitempty"`
+}
+
+// GetGradingQueue 获取考试的主观题判分队列，reviewed=true 时返回已判分的作答以便复核
+func GetGradingQueue(c *gin.Context) {
+	examID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的考试ID"})
+		return
+	}
+
+	var query struct {
+		Reviewed bool `form:"reviewed"`
+		Page     int  `form:"page,default=1"`
+		Limit    int  `form:"limit,default=20"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.Limit < 1 || query.Limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit value (1-100)"})
+		return
+	}
+
+	db := DB.Table("exam_answers").
+		Joins("JOIN exam_attempts ON exam_attempts.id = exam_answers.attempt_id").
+		Joins("JOIN questions ON questions.id = exam_answers.question_id").
+		Joins("LEFT JOIN users ON users.id = exam_attempts.user_id").
+		Where("exam_attempts.exam_id = ? AND questions.type = ?", examID, models.QuestionTypeSubjective).
+		Where("exam_answers.deleted_at IS NULL AND exam_attempts.deleted_at IS NULL")
+	if query.Reviewed {
+		db = db.Where("exam_answers.reviewed_at IS NOT NULL")
+	} else {
+		db = db.Where("exam_answers.reviewed_at IS NULL AND exam_attempts.status = ?", models.AttemptStatusPending)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "数据库查询失败"})
+		return
+	}
+
+	var rows []struct {
+		GradingQueueItem
+		BankID        uint
+		QuestionScore int
+		StartTime     time.Time
+	}
+	offset := (query.Page - 1) * query.Limit
+	if err := db.Select(`exam_answers.id AS answer_id, exam_answers.attempt_id, exam_attempts.user_id,
+		users.name AS user_name, exam_answers.question_id, questions.content, exam_answers.user_answer,
+		questions.reference, questions.analysis, exam_answers.score, exam_answers.comment,
+		exam_attempts.end_time AS submitted_at, exam_answers.reviewed_at,
+		questions.bank_id, questions.score AS question_score, exam_attempts.start_time`).
+		Order("exam_attempts.end_time ASC, exam_answers.id ASC").
+		Offset(offset).Limit(query.Limit).
+		Scan(&rows).Error; err != nil {
+		log.Errorln(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "数据库查询失败"})
+		return
+	}
+
+	// 满分与判分时一致，按各答卷开考时生效的分数规则计算
+	rulesByAttempt := make(map[uint][]models.ExamScoreRule)
+	items := make([]GradingQueueItem, 0, len(rows))
+	for _, row := range rows {
+		item := row.GradingQueueItem
+		rules, ok := rulesByAttempt[item.AttemptID]
+		if !ok {
+			if rules, err = models.ScoreRulesAt(DB, uint(examID), row.StartTime); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "数据库查询失败"})
+				return
+			}
+			rulesByAttempt[item.AttemptID] = rules
+		}
+		q := models.Question{BankID: row.BankID, Type: models.QuestionTypeSubjective, Score: row.QuestionScore}
+		item.MaxScore = grading.PointsFor(&q, rules)
+		// 作答以 JSON 字符串保存，展示时还原为文本
+		var text string
+		if json.Unmarshal([]byte(item.UserAnswer), &text) == nil {
+			item.UserAnswer = text
+		}
+		items = append(items, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": items,
+		"pagination": gin.H{
+			"page":  query.Page,
+			"limit": query.Limit,
+			"total": total,
+			"pages": int(math.Ceil(float64(total) / float64(query.Limit))),
+		},
+	})
+}
+
+// GradeAnswerRequest 人工判分请求体
+type GradeAnswerRequest struct {
+	Score   *float64 `json:"score" binding:"required"`
+	Comment string   `json:"comment"`
+}
+
+// GradeAnswer 对主观题作答给分，全部主观题判完后确定考试成绩
+func GradeAnswer(c *gin.Context) {
+	answerID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的作答ID"})
+		return
+	}
+
+	var req GradeAnswerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var attempt models.ExamAttempt
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := grading.ReviewAnswer(tx, uint(answerID), currentUserID(c), *req.Score, req.Comment); err != nil {
+			return err
+		}
+		return tx.Joins("JOIN exam_answers ON exam_answers.attempt_id = exam_attempts.id").
+			Where("exam_answers.id = ?", answerID).
+			First(&attempt).Error
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, grading.ErrAnswerNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, grading.ErrNotSubjective), errors.Is(err, grading.ErrScoreOutOfRange):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, grading.ErrNotSubmitted):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			log.Errorln(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "判分失败"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"attempt_id": attempt.ID,
+		"status":     attempt.Status,
+		"score":      attempt.Score,
+		"is_passed":  attempt.IsPassed,
+	})
+}
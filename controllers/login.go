@@ -1,9 +1,10 @@
 package controllers
 
 import (
-	"fmt"
-	"math/rand"
+	"context"
+	"errors"
 	"mio/gin-example/models"
+	"mio/gin-example/verifycode"
 	"net/http"
 	"time"
 
@@ -13,13 +14,8 @@ import (
 	"gorm.io/gorm"
 )
 
-// 验证码缓存
-var codeCache = make(map[string]codeInfo)
-
-type codeInfo struct {
-	code      string
-	expiresAt time.Time
-}
+// SMSCodes 短信验证码服务，由 main 在启动时初始化
+var SMSCodes *verifycode.Service
 
 func HandleLogin(c *gin.Context) {
 	var req struct {
@@ -33,35 +29,18 @@ func HandleLogin(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效请求"})
 		return
 	}
-	log.Printf("请求参数: %v", req) // 添加日志
+	log.Printf("登录请求: %s", req.Phone) // 不记录密码与验证码
 
 	// 查找用户
 	var user models.User
 	if err := DB.Where("phone = ?", req.Phone).First(&user).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			userInput := &models.User{
-				Name:      "张三",
-				Phone:     "123123123",
-				Email:     "zhangsan@example.com",
-				Password:  "123",
-				Role:      "user",
-				CompanyID: 1,
-				Status:    true,
-			}
-			hash, err := bcrypt.GenerateFromPassword([]byte(userInput.Password), 12)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": err,
-				})
-				log.Errorln(err)
-				return
-			}
-			userInput.Password = string(hash)
-			models.CreateUser(DB, userInput)
-			log.Printf("用户注册成功: %s", req.Phone) // 添加日志
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未注册"})
 			return
 		}
-		// c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未注册"})
+		log.Error("查询用户失败: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "登录失败"})
+		return
 	}
 
 	// 检查审核状态
@@ -71,92 +50,96 @@ func HandleLogin(c *gin.Context) {
 	}
 
 	// 验证登录方式
-	// if req.Code != "" {
-	// 	if !verifyCode(req.Phone, req.Code) {
-	// 		c.JSON(http.StatusUnauthorized, gin.H{"error": "验证码错误或已过期"})
-	// 		return
-	// 	}
-	// } else if req.Password != "" {
-	// 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-	// 		c.JSON(http.StatusUnauthorized, gin.H{"error": "密码错误"})
-	// 		return
-	// 	}
-	// } else {
-	// 	c.JSON(http.StatusBadRequest, gin.H{"error": "请选择登录方式"})
-	// 	return
-	// }
-	if req.Password != "" {
+	if req.Code != "" {
+		if err := SMSCodes.Verify(c.Request.Context(), req.Phone, req.Code); err != nil {
+			handleCodeError(c, err)
+			return
+		}
+	} else if req.Password != "" {
 		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "密码错误"})
 			log.Printf("密码错误: %s", req.Phone) // 添加日志
 			return
 		}
+	} else {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请选择登录方式"})
+		return
 	}
 
 	// 生成JWT
-	token, err := generateJWT(user)
+	resp, err := issueTokens(c, &user)
 	if err != nil {
+		log.Error("生成令牌失败: ", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
 		return
 	}
 
-	// 更新最后登录时间
-	models.Refresh(DB, user.ID, user.TokenVersion+1)
+	resp["user_id"] = user.ID
+	c.JSON(http.StatusOK, resp)
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"token":   token,
-		"user_id": user.ID,
-	})
+// SendCodeRequest 发送验证码请求体
+type SendCodeRequest struct {
+	Phone string `json:"phone" binding:"required"`
 }
 
-// 处理发送验证码
-func handleSendCode(c *gin.Context) {
-	var req struct {
-		Phone string `json:"phone" binding:"required"`
-	}
+// SendLoginCode 向已注册的手机号发送登录验证码
+// @Summary 发送登录验证码
+// @Tags 登录
+// @Accept json
+// @Produce json
+// @Param body body SendCodeRequest true "手机号"
+// @Router /login/code [post]
+func SendLoginCode(c *gin.Context) {
+	var req SendCodeRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效请求"})
 		return
 	}
 
-	code := generateRandomCode(6)
-	expiration := time.Now().Add(5 * time.Minute)
-
-	// 存储验证码
-	codeCache[req.Phone] = codeInfo{
-		code:      code,
-		expiresAt: expiration,
+	// 只向已注册的手机号发送，避免短信被滥用
+	var user models.User
+	if err := DB.Select("id").Where("phone = ?", req.Phone).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未注册"})
+			return
+		}
+		log.Error("查询用户失败: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "发送验证码失败"})
+		return
 	}
 
-	// TODO: 集成短信发送服务
-	fmt.Printf("发送验证码到 %s: %s\n", req.Phone, code)
+	if err := SMSCodes.Send(c.Request.Context(), req.Phone); err != nil {
+		handleCodeError(c, err)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "验证码已发送"})
 }
 
-// 生成随机验证码
-func generateRandomCode(length int) string {
-	rand.Seed(time.Now().UnixNano())
-	const digits = "0123456789"
-	code := make([]byte, length)
-	for i := range code {
-		code[i] = digits[rand.Intn(len(digits))]
-	}
-	return string(code)
+// StartSMSCodeSweeper 定期清理过期的短信验证码
+func StartSMSCodeSweeper(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := SMSCodes.DeleteExpired(context.Background()); err != nil {
+				log.Errorf("清理过期验证码失败: %v", err)
+			}
+		}
+	}()
 }
 
-// 验证验证码
-func verifyCode(phone, code string) bool {
-	info, exists := codeCache[phone]
-	if !exists {
-		return false
+// handleCodeError 将验证码相关错误映射为HTTP状态码
+func handleCodeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, verifycode.ErrInvalidCode), errors.Is(err, verifycode.ErrTooManyAttempts):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, verifycode.ErrCooldown):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	default:
+		log.Error("验证码处理失败: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "验证码服务暂不可用"})
 	}
-
-	if time.Now().After(info.expiresAt) {
-		delete(codeCache, phone)
-		return false
-	}
-
-	return info.code == code
 }
@@ -1,11 +1,566 @@
 package controllers
 
-import "github.com/gin-gonic/gin"
+import (
+	"errors"
+	"math"
+	"mio/gin-example/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// QuestionBankRequest 创建/更新题库请求体
+type QuestionBankRequest struct {
+	Name         *string `json:"name"`
+	Description  *string `json:"description"`
+	QuestionType *string `json:"question_type"`
+}
+
+// QuestionRequest 创建/更新题目请求体
+type QuestionRequest struct {
+	Type       *string       `json:"type"`
+	Content    *string       `json:"content"`
+	Difficulty *int          `json:"difficulty"`
+	Score      *int          `json:"score"`
+	Options    *models.JSONB `json:"options"`
+	Answers    *models.JSONB `json:"answers"`
+	Candidates *models.JSONB `json:"candidates"`
+	Reference  *string       `json:"reference"`
+	Analysis   *string       `json:"analysis"`
+}
+
+// CreateQuestionBank 创建题库
 func CreateQuestionBank(c *gin.Context) {
+	var req QuestionBankRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	trimRequestFields(&req)
 
+	if req.Name == nil || *req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "题库名称不能为空"})
+		return
+	}
+	if req.QuestionType == nil || !models.IsValidQuestionType(*req.QuestionType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的题目类型"})
+		return
+	}
+
+	bank := models.QuestionBank{
+		Name:         *req.Name,
+		QuestionType: *req.QuestionType,
+	}
+	if req.Description != nil {
+		bank.Description = *req.Description
+	}
+
+	if err := DB.Create(&bank).Error; err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "题库名称已存在"})
+			return
+		}
+		log.Errorln(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建题库失败"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, bank)
 }
 
+// GetQuestionBanks 获取题库列表，deleted=true 时只返回已删除的题库
 func GetQuestionBanks(c *gin.Context) {
+	var query struct {
+		Name         string `form:"name"`
+		QuestionType string `form:"question_type"`
+		Deleted      bool   `form:"deleted"`
+		Page         int    `form:"page,default=1"`
+		Limit        int    `form:"limit,default=20"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.Limit < 1 || query.Limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit value (1-100)"})
+		return
+	}
+
+	db := DB.Model(&models.QuestionBank{})
+	if query.Deleted {
+		db = db.Unscoped().Where("deleted_at IS NOT NULL")
+	}
+	if query.Name != "" {
+		db = db.Where("name LIKE ?", "%"+query.Name+"%")
+	}
+	if query.QuestionType != "" {
+		db = db.Where("question_type = ?", query.QuestionType)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "数据库查询失败"})
+		return
+	}
+
+	var banks []models.QuestionBank
+	offset := (query.Page - 1) * query.Limit
+	if err := db.Order("id desc").Offset(offset).Limit(query.Limit).Find(&banks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "数据库查询失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": banks,
+		"pagination": gin.H{
+			"page":  query.Page,
+			"limit": query.Limit,
+			"total": total,
+			"pages": int(math.Ceil(float64(total) / float64(query.Limit))),
+		},
+	})
+}
+
+// GetQuestionBank 获取单个题库
+func GetQuestionBank(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的题库ID"})
+		return
+	}
+
+	var bank models.QuestionBank
+	if err := DB.First(&bank, id).Error; err != nil {
+		handleQuestionBankError(c, err)
+		return
+	}
+
+	var count int64
+	DB.Model(&models.Question{}).Where("bank_id = ?", bank.ID).Count(&count)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":           bank,
+		"question_count": count,
+	})
+}
+
+// UpdateQuestionBank 更新题库
+func UpdateQuestionBank(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的题库ID"})
+		return
+	}
+
+	var req QuestionBankRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	trimRequestFields(&req)
+
+	var bank models.QuestionBank
+	if err := DB.First(&bank, id).Error; err != nil {
+		handleQuestionBankError(c, err)
+		return
+	}
+
+	if req.Name != nil {
+		if *req.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "题库名称不能为空"})
+			return
+		}
+		bank.Name = *req.Name
+	}
+	if req.Description != nil {
+		bank.Description = *req.Description
+	}
+	if req.QuestionType != nil {
+		if !models.IsValidQuestionType(*req.QuestionType) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的题目类型"})
+			return
+		}
+		if *req.QuestionType != bank.QuestionType {
+			// 题库只能容纳一种题型，已有题目时不能改为其他题型
+			var n int64
+			if err := DB.Model(&models.Question{}).Where("bank_id = ?", bank.ID).Count(&n).Error; err != nil {
+				handleQuestionBankError(c, err)
+				return
+			}
+			if n > 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "题库中已有题目，不能修改题目类型"})
+				return
+			}
+		}
+		bank.QuestionType = *req.QuestionType
+	}
+
+	if err := DB.Save(&bank).Error; err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "题库名称已存在"})
+			return
+		}
+		log.Errorln(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新题库失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, bank)
+}
+
+// DeleteQuestionBank 软删除题库及其下的题目
+func DeleteQuestionBank(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的题库ID"})
+		return
+	}
+
+	// 题库与题目使用同一删除时间，恢复时据此区分单独删除过的题目
+	now := time.Now()
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		var bank models.QuestionBank
+		if err := tx.First(&bank, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Question{}).
+			Where("bank_id = ?", bank.ID).
+			UpdateColumn("deleted_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&bank).UpdateColumn("deleted_at", now).Error
+	})
+	if err != nil {
+		handleQuestionBankError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "题库删除成功"})
+}
+
+// RestoreQuestionBank 恢复已删除的题库及随之删除的题目
+func RestoreQuestionBank(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的题库ID"})
+		return
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		var bank models.QuestionBank
+		if err := tx.Unscoped().Where("deleted_at IS NOT NULL").First(&bank, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&models.Question{}).
+			Where("bank_id = ? AND deleted_at = ?", bank.ID, bank.DeletedAt).
+			UpdateColumn("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Model(&bank).UpdateColumn("deleted_at", nil).Error
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "已存在同名题库"})
+			return
+		}
+		handleQuestionBankError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "题库恢复成功"})
+}
+
+// CreateQuestion 在题库下创建题目
+func CreateQuestion(c *gin.Context) {
+	bankID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的题库ID"})
+		return
+	}
+
+	var bank models.QuestionBank
+	if err := DB.First(&bank, bankID).Error; err != nil {
+		handleQuestionBankError(c, err)
+		return
+	}
+
+	var req QuestionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	trimRequestFields(&req)
+
+	// 未指定题型时沿用题库题型，指定的题型必须与题库一致
+	question := models.Question{
+		BankID:     bank.ID,
+		Type:       bank.QuestionType,
+		Difficulty: 3,
+		Score:      10,
+	}
+	applyQuestionRequest(&question, &req)
+
+	if question.Type != bank.QuestionType {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "题目类型与题库类型不一致"})
+		return
+	}
+	if err := question.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := DB.Create(&question).Error; err != nil {
+		log.Errorln(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建题目失败"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, question)
+}
+
+// GetQuestions 获取题库下的题目列表
+func GetQuestions(c *gin.Context) {
+	bankID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的题库ID"})
+		return
+	}
+
+	var query struct {
+		Type       string `form:"type"`
+		Difficulty int    `form:"difficulty"`
+		Keyword    string `form:"keyword"`
+		Deleted    bool   `form:"deleted"`
+		Page       int    `form:"page,default=1"`
+		Limit      int    `form:"limit,default=20"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.Limit < 1 || query.Limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit value (1-100)"})
+		return
+	}
+
+	db := DB.Model(&models.Question{}).Where("bank_id = ?", bankID)
+	if query.Deleted {
+		db = db.Unscoped().Where("deleted_at IS NOT NULL")
+	}
+	if query.Type != "" {
+		db = db.Where("type = ?", query.Type)
+	}
+	if query.Difficulty > 0 {
+		db = db.Where("difficulty = ?", query.Difficulty)
+	}
+	if query.Keyword != "" {
+		db = db.Where("content LIKE ?", "%"+query.Keyword+"%")
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "数据库查询失败"})
+		return
+	}
+
+	var questions []models.Question
+	offset := (query.Page - 1) * query.Limit
+	if err := db.Order("id asc").Offset(offset).Limit(query.Limit).Find(&questions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "数据库查询失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": questions,
+		"pagination": gin.H{
+			"page":  query.Page,
+			"limit": query.Limit,
+			"total": total,
+			"pages": int(math.Ceil(float64(total) / float64(query.Limit))),
+		},
+	})
+}
+
+// GetQuestion 获取单个题目
+func GetQuestion(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的题目ID"})
+		return
+	}
+
+	var question models.Question
+	if err := DB.First(&question, id).Error; err != nil {
+		handleQuestionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, question)
+}
+
+// UpdateQuestion 更新题目，合并后整体重新校验
+func UpdateQuestion(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的题目ID"})
+		return
+	}
+
+	var question models.Question
+	if err := DB.First(&question, id).Error; err != nil {
+		handleQuestionError(c, err)
+		return
+	}
+
+	var req QuestionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	trimRequestFields(&req)
+	applyQuestionRequest(&question, &req)
+
+	var bank models.QuestionBank
+	if err := DB.Select("id", "question_type").First(&bank, question.BankID).Error; err != nil {
+		handleQuestionBankError(c, err)
+		return
+	}
+	if question.Type != bank.QuestionType {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "题目类型与题库类型不一致"})
+		return
+	}
+	if err := question.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := DB.Save(&question).Error; err != nil {
+		log.Errorln(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新题目失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, question)
+}
+
+// DeleteQuestion 软删除题目
+func DeleteQuestion(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的题目ID"})
+		return
+	}
+
+	result := DB.Delete(&models.Question{}, id)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除题目失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "题目不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "题目删除成功"})
+}
+
+// RestoreQuestion 恢复已删除的题目，所属题库仍处于删除状态时拒绝恢复
+func RestoreQuestion(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的题目ID"})
+		return
+	}
+
+	var question models.Question
+	if err := DB.Unscoped().Where("deleted_at IS NOT NULL").First(&question, id).Error; err != nil {
+		handleQuestionError(c, err)
+		return
+	}
+
+	var bank models.QuestionBank
+	if err := DB.First(&bank, question.BankID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusConflict, gin.H{"error": "所属题库已删除，请先恢复题库"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "数据库查询失败"})
+		return
+	}
+
+	if err := DB.Unscoped().Model(&question).UpdateColumn("deleted_at", nil).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "恢复题目失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "题目恢复成功"})
+}
+
+func applyQuestionRequest(q *models.Question, req *QuestionRequest) {
+	if req.Type != nil {
+		q.Type = *req.Type
+	}
+	if req.Content != nil {
+		q.Content = *req.Content
+	}
+	if req.Difficulty != nil {
+		q.Difficulty = *req.Difficulty
+	}
+	if req.Score != nil {
+		q.Score = *req.Score
+	}
+	if req.Options != nil {
+		q.Options = *req.Options
+	}
+	if req.Answers != nil {
+		q.Answers = *req.Answers
+	}
+	if req.Candidates != nil {
+		q.Candidates = *req.Candidates
+	}
+	if req.Reference != nil {
+		q.Reference = *req.Reference
+	}
+	if req.Analysis != nil {
+		q.Analysis = *req.Analysis
+	}
+}
+
+func handleQuestionBankError(c *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "题库不存在"})
+		return
+	}
+	log.Errorln(err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "数据库操作失败"})
+}
+
+func handleQuestionError(c *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "题目不存在"})
+		return
+	}
+	log.Errorln(err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "数据库操作失败"})
+}
 
+// 判断是否为唯一约束冲突
+func isUniqueViolation(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, gorm.ErrDuplicatedKey) ||
+		strings.Contains(err.Error(), "UNIQUE constraint failed") ||
+		strings.Contains(err.Error(), "Duplicate entry")
 }
@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"errors"
+	"mio/gin-example/models"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// RefreshTokenRequest 刷新令牌请求体
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// LoginSessionResponse 登录设备列表项
+type LoginSessionResponse struct {
+	models.LoginSession
+	Current bool `json:"current"` // 是否为发起请求的设备
+}
+
+// issueTokens 为用户创建登录会话，返回访问令牌与刷新令牌
+func issueTokens(c *gin.Context, user *models.User) (gin.H, error) {
+	session, refreshToken, err := models.CreateLoginSession(DB, user, deviceName(c), c.ClientIP(),
+		time.Duration(Conf.Auth.RefreshTTL)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return tokenResponse(user, session, refreshToken)
+}
+
+func tokenResponse(user *models.User, session *models.LoginSession, refreshToken string) (gin.H, error) {
+	token, err := generateJWT(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	return gin.H{
+		"token":         token,
+		"refresh_token": refreshToken,
+		"expires_in":    Conf.Auth.AccessTTL,
+	}, nil
+}
+
+// deviceName 客户端可通过 X-Device-Name 指定设备名称，否则使用 User-Agent
+func deviceName(c *gin.Context) string {
+	name := c.GetHeader("X-Device-Name")
+	if name == "" {
+		name = c.Request.UserAgent()
+	}
+	for utf8.RuneCountInString(name) > 100 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+// RefreshToken 用刷新令牌换取新的访问令牌与刷新令牌，旧刷新令牌随即失效
+// @Summary 刷新登录令牌
+// @Tags 登录
+// @Accept json
+// @Produce json
+// @Param body body RefreshTokenRequest true "刷新令牌"
+// @Router /token/refresh [post]
+func RefreshToken(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, user, refreshToken, err := models.RotateRefreshToken(DB, req.RefreshToken,
+		time.Duration(Conf.Auth.RefreshTTL)*time.Second)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRefreshTokenReused):
+			log.Warnf("刷新令牌重复使用，已吊销会话: %s", c.ClientIP())
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrRefreshTokenInvalid):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrUserDisabled):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			log.Error("刷新令牌失败: ", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "刷新令牌失败"})
+		}
+		return
+	}
+
+	resp, err := tokenResponse(user, session, refreshToken)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Logout 退出当前设备的登录，其他设备不受影响
+// @Summary 退出登录
+// @Tags 登录
+// @Router /logout [post]
+func Logout(c *gin.Context) {
+	err := models.RevokeLoginSession(DB, currentUserID(c), c.GetString(SessionIDKey), models.RevokeLogout)
+	if err != nil && !errors.Is(err, models.ErrSessionNotFound) {
+		log.Error("退出登录失败: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "退出登录失败"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetLoginSessions 列出当前用户已登录的设备
+// @Summary 已登录设备
+// @Tags 登录
+// @Produce json
+// @Router /session [get]
+func GetLoginSessions(c *gin.Context) {
+	sessions, err := models.ActiveLoginSessions(DB, currentUserID(c))
+	if err != nil {
+		log.Error("查询登录设备失败: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询登录设备失败"})
+		return
+	}
+
+	current := c.GetString(SessionIDKey)
+	resp := make([]LoginSessionResponse, len(sessions))
+	for i, s := range sessions {
+		resp[i] = LoginSessionResponse{LoginSession: s, Current: s.ID == current}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// DeleteLoginSession 让指定设备退出登录
+// @Summary 移除登录设备
+// @Tags 登录
+// @Param id path string true "会话ID"
+// @Router /session/{id} [delete]
+func DeleteLoginSession(c *gin.Context) {
+	err := models.RevokeLoginSession(DB, currentUserID(c), c.Param("id"), models.RevokeLogout)
+	if err != nil {
+		if errors.Is(err, models.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Error("移除登录设备失败: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "移除登录设备失败"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetJWKS 公开令牌签名公钥，供其他服务验证本服务签发的令牌
+// @Summary 令牌签名公钥
+// @Tags 登录
+// @Produce json
+// @Router /.well-known/jwks.json [get]
+func GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, Keys.JWKS())
+}
+
+// StartSessionSweeper 定期清理过期的登录会话与刷新令牌
+func StartSessionSweeper(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			n, err := models.DeleteExpiredLoginSessions(DB, now)
+			if err != nil {
+				log.Errorf("清理过期登录会话失败: %v", err)
+				continue
+			}
+			if n > 0 {
+				log.Infof("清理过期登录会话 %d 个", n)
+			}
+		}
+	}()
+}
@@ -21,12 +21,15 @@ func GetUser(c *gin.Context) {
 
 	var user models.User
 	result := DB.Model(&models.User{}).
-		Select("id", "name", "email", "age", "role", "company_id", "created_at", "updated_at").
+		Select("id", "name", "email", "age", "role", "status", "company_id", "created_at", "updated_at").
 		Preload("Company", func(db *gorm.DB) *gorm.DB {
 			return db.Select("id", "name")
 		}).
-		Preload("Courses", func(db *gorm.DB) *gorm.DB {
-			return db.Select("id", "title", "progress")
+		Preload("Enrollments", func(db *gorm.DB) *gorm.DB {
+			return db.Order("enrolled_at DESC")
+		}).
+		Preload("Enrollments.Course", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id", "name")
 		}).
 		First(&user, userID)
 
@@ -40,31 +43,10 @@ func GetUser(c *gin.Context) {
 		return
 	}
 
-	// 构造安全响应结构体
-	type SafeUser struct {
-		ID        uint      `json:"id"`
-		Name      string    `json:"name"`
-		Email     string    `json:"email"`
-		Age       *uint8    `json:"age,omitempty"`
-		Role      string    `json:"role"`
-		CreatedAt time.Time `json:"createdAt"`
-		UpdatedAt time.Time `json:"updatedAt"`
-	}
-
-	response := SafeUser{
-		ID:        user.ID,
-		Name:      user.Name,
-		Email:     user.Email,
-		Age:       user.Age,
-		Role:      user.Role,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
-	}
-
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusOK, newSafeUser(user))
 }
 
-// GET /users?page=2&limit=10&sort=created_at desc&fields=name,email&role=admin
+// GET /users?page=2&limit=10&sort=created_at desc&fields=name,email&role=admin&status=false
 func GetUsers(c *gin.Context) {
 	// 初始化查询
 	query := DB.Model(&models.User{})
@@ -94,11 +76,15 @@ func GetUsers(c *gin.Context) {
 	if role := c.Query("role"); role != "" {
 		query = query.Where("role = ?", role)
 	}
+	if status := c.Query("status"); status != "" {
+		// status=false 查询待审核的用户
+		query = query.Where("status = ?", status == "true")
+	}
 
 	// 字段选择（白名单机制）
 	validFields := map[string]bool{
 		"id": true, "name": true, "email": true,
-		"age": true, "role": true, "company_id": true,
+		"age": true, "role": true, "status": true, "company_id": true,
 		"created_at": true, "updated_at": true,
 	}
 	if fields != "" {
@@ -117,7 +103,11 @@ func GetUsers(c *gin.Context) {
 	// 预加载关联数据
 	query = query.Preload("Company", func(db *gorm.DB) *gorm.DB {
 		return db.Select("id, name") // 只加载公司关键字段
-	}).Preload("Courses")
+	}).Preload("Enrollments", func(db *gorm.DB) *gorm.DB {
+		return db.Order("enrolled_at DESC")
+	}).Preload("Enrollments.Course", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id", "name")
+	})
 
 	// 执行分页查询
 	var total int64
@@ -135,9 +125,13 @@ func GetUsers(c *gin.Context) {
 		return
 	}
 
-	// 构造响应数据结构
+	// 构造响应数据结构，与用户详情一致，报名记录只返回课程与学习情况
+	safeUsers := make([]SafeUser, len(users))
+	for i, user := range users {
+		safeUsers[i] = newSafeUser(user)
+	}
 	response := gin.H{
-		"data": users,
+		"data": safeUsers,
 		"pagination": gin.H{
 			"page":  page,
 			"limit": limit,
@@ -167,6 +161,7 @@ func UpdateUser(c *gin.Context) {
 		Age      *uint8  `json:"age" validate:"omitempty,min=1,max=100"`
 		Password *string `json:"password" validate:"omitempty,min=8"`
 		Role     *string `json:"role" validate:"omitempty,oneof=user admin"`
+		Status   *bool   `json:"status"` // 审核通过或停用账号
 	}
 
 	if err := c.ShouldBindJSON(&updateData); err != nil {
@@ -208,6 +203,9 @@ func UpdateUser(c *gin.Context) {
 	if updateData.Role != nil {
 		updates["role"] = *updateData.Role
 	}
+	if updateData.Status != nil {
+		updates["status"] = *updateData.Status
+	}
 
 	// 执行更新
 	if err := DB.Model(&existingUser).Updates(updates).Error; err != nil {
@@ -229,8 +227,8 @@ func DeleteUser(c *gin.Context) {
 			return err
 		}
 
-		// 删除关联课程
-		if err := tx.Model(&user).Association("Courses").Delete(); err != nil {
+		// 删除报名记录
+		if err := models.DeleteUserEnrollments(tx, user.ID); err != nil {
 			return err
 		}
 
@@ -255,6 +253,60 @@ func DeleteUser(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+// SafeUser 返回给前端的用户信息，不含密码等敏感字段
+type SafeUser struct {
+	ID        uint             `json:"id"`
+	Name      string           `json:"name"`
+	Email     *string          `json:"email"`
+	Age       *uint8           `json:"age,omitempty"`
+	Role      string           `json:"role"`
+	Status    bool             `json:"status"`
+	Company   string           `json:"company,omitempty"`
+	Courses   []EnrolledCourse `json:"courses"`
+	CreatedAt time.Time        `json:"createdAt"`
+	UpdatedAt time.Time        `json:"updatedAt"`
+}
+
+func newSafeUser(user models.User) SafeUser {
+	return SafeUser{
+		ID:        user.ID,
+		Name:      user.Name,
+		Email:     user.Email,
+		Age:       user.Age,
+		Role:      user.Role,
+		Status:    user.Status,
+		Company:   user.Company.Name,
+		Courses:   enrolledCourses(user.Enrollments),
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
+}
+
+// EnrolledCourse 用户报名的课程及学习情况
+type EnrolledCourse struct {
+	CourseID    uint       `json:"course_id"`
+	CourseName  string     `json:"course_name"`
+	EnrolledAt  time.Time  `json:"enrolled_at"`
+	Progress    uint       `json:"progress"`
+	IsCompleted bool       `json:"is_completed"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+func enrolledCourses(enrollments []models.Enrollment) []EnrolledCourse {
+	courses := make([]EnrolledCourse, len(enrollments))
+	for i, e := range enrollments {
+		courses[i] = EnrolledCourse{
+			CourseID:    e.CourseID,
+			CourseName:  e.Course.Name,
+			EnrolledAt:  e.EnrolledAt,
+			Progress:    e.Progress,
+			IsCompleted: e.IsCompleted,
+			CompletedAt: e.CompletedAt,
+		}
+	}
+	return courses
+}
+
 // 辅助函数
 func handleUserError(c *gin.Context, err error) {
 	if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -57,7 +57,7 @@ func GetVideos(c *gin.Context) {
 
 // GetVideo 获取单个视频
 // @Summary 获取单个视频
-// @Description 根据ID获取视频详情
+// @Description 根据ID获取视频详情，包含字幕与章节
 // @Tags 视频管理
 // @Produce json
 // @Param id path int true "视频ID"
@@ -74,6 +74,8 @@ func GetVideo(c *gin.Context) {
 	if err := DB.
 		Preload("Course").
 		Preload("Unit").
+		Preload("Subtitles").
+		Preload("Chapters", models.OrderChapters).
 		First(&video, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "视频不存在"})
@@ -88,50 +90,48 @@ func GetVideo(c *gin.Context) {
 
 // CreateVideoRequest 创建视频请求体
 type CreateVideoRequest struct {
-	CourseID    uint    `json:"course_id" binding:"required"`
-	UnitID      uint    `json:"unit_id" binding:"required"`
-	Title       string  `json:"title" binding:"required"`
-	URL         string  `json:"url" binding:"required"`
-	Duration    int     `json:"duration"`
-	Description *string `json:"description"`
+	CourseID    uint   `json:"course_id" binding:"required"`
+	UnitID      *uint  `json:"unit_id"`
+	Title       string `json:"title" binding:"required,max=100"`
+	URL         string `json:"url" binding:"required,max=255"`
+	Duration    int    `json:"duration"`
+	Description string `json:"description"`
+	IsMandatory *bool  `json:"is_mandatory"`
 }
 
 // CreateVideo 创建视频
 // @Summary 创建视频
-// @Description 创建新视频
+// @Description 以外部地址创建视频，上传文件见 UploadVideo
 // @Tags 视频管理
 // @Accept json
 // @Produce json
 // @Param body body CreateVideoRequest true "视频信息"
 // @Success 201 {object} models.Video
 // @Router /admin/course/video [post]
-// func CreateVideo(c *gin.Context) {
-// 	var req CreateVideoRequest
-// 	if err := c.ShouldBindJSON(&req); err != nil {
-// 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-// 		return
-// 	}
-
-// 	video := models.Video{
-// 		CourseID:    req.CourseID,
-// 		UnitID:      req.UnitID,
-// 		Title:       req.Title,
-// 		URL:         req.URL,
-// 		Duration:    req.Duration,
-// 		Description: req.Description,
-// 	}
-
-// 	if err := DB.Create(&video).Error; err != nil {
-// 		if isDuplicateError(err) {
-// 			c.JSON(http.StatusConflict, gin.H{"error": "同课程下视频标题不能重复"})
-// 			return
-// 		}
-// 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建视频失败"})
-// 		return
-// 	}
-
-// 	c.JSON(http.StatusCreated, video)
-// }
+func CreateVideo(c *gin.Context) {
+	var req CreateVideoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	video := models.Video{
+		CourseID:    req.CourseID,
+		UnitID:      req.UnitID,
+		Title:       req.Title,
+		URL:         req.URL,
+		Duration:    req.Duration,
+		Description: req.Description,
+		IsMandatory: req.IsMandatory == nil || *req.IsMandatory,
+	}
+
+	if err := courseService().CreateVideo(&video); err != nil {
+		handleCourseError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, video)
+}
 
 // UpdateVideoRequest 更新视频请求体
 type UpdateVideoRequest struct {
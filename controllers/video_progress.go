@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"errors"
+	"mio/gin-example/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// HeartbeatRequest 观看心跳请求体
+type HeartbeatRequest struct {
+	SessionID string   `json:"session_id" binding:"required,max=64"`
+	Position  *float64 `json:"position" binding:"required"`
+}
+
+// VideoHeartbeat 上报视频播放位置，服务端据此校验并更新观看进度
+func VideoHeartbeat(c *gin.Context) {
+	videoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的视频ID"})
+		return
+	}
+
+	var req HeartbeatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy := models.HeartbeatPolicy{
+		CompleteThreshold: Conf.Video.CompleteThreshold,
+		MaxPlaybackRate:   Conf.Video.MaxPlaybackRate,
+		JumpTolerance:     Conf.Video.JumpTolerance,
+	}
+	progress, err := models.RecordHeartbeat(DB, currentUserID(c), uint(videoID),
+		models.Heartbeat{SessionID: req.SessionID, Position: *req.Position}, policy)
+	switch {
+	case errors.Is(err, models.ErrVideoNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, models.ErrNotEnrolled):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, models.ErrImplausibleProgress):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Error("记录观看进度失败: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "记录观看进度失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"position":     progress.Position,
+		"progress":     progress.Progress,
+		"is_completed": progress.IsCompleted,
+	})
+}
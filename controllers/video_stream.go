@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mio/gin-example/models"
+	"mio/gin-example/storage"
+	"mio/gin-example/videolink"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// Links 视频播放链接签名器，由 main 在启动时初始化
+var Links *videolink.Signer
+
+// GetVideoPlayURL 签发视频播放链接
+// @Summary 获取视频播放地址
+// @Description 已报名学员获取带签名的播放地址与字幕、章节，地址只对当前用户有效且会过期；外部地址的视频直接返回原地址
+// @Tags 视频播放
+// @Produce json
+// @Param id path int true "视频ID"
+// @Router /video/{id}/play [get]
+func GetVideoPlayURL(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的视频ID"})
+		return
+	}
+
+	userID := currentUserID(c)
+	video, err := models.FindPlayableVideo(DB, userID, uint(id))
+	if err != nil {
+		handlePlayError(c, err)
+		return
+	}
+
+	var subtitles []models.VideoSubtitle
+	var chapters []models.VideoChapter
+	if err := DB.Where("video_id = ?", video.ID).Find(&subtitles).Error; err != nil {
+		handlePlayError(c, err)
+		return
+	}
+	if err := DB.Scopes(models.OrderChapters).Where("video_id = ?", video.ID).Find(&chapters).Error; err != nil {
+		handlePlayError(c, err)
+		return
+	}
+	resp := gin.H{"url": video.URL, "subtitles": subtitles, "chapters": chapters}
+
+	if video.StorageKey != "" {
+		q, expires := Links.Sign(video.ID, userID)
+		resp["url"] = fmt.Sprintf("/v1/video/%d/stream?%s", video.ID, q.Encode())
+		resp["expires_at"] = expires
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// StreamVideo 按签名链接输出视频内容，支持 Range 与 If-Range 以便播放器拖动进度
+// @Summary 视频流
+// @Tags 视频播放
+// @Param id path int true "视频ID"
+// @Param uid query int true "用户ID"
+// @Param exp query int true "过期时间"
+// @Param sig query string true "签名"
+// @Router /video/{id}/stream [get]
+func StreamVideo(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的视频ID"})
+		return
+	}
+
+	userID, err := Links.Verify(uint(id), c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	// 链接有效期内仍需校验报名，退课或被移出课程后立即失效
+	video, err := models.FindPlayableVideo(DB, userID, uint(id))
+	if err != nil {
+		handlePlayError(c, err)
+		return
+	}
+	if video.StorageKey == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "视频文件不存在"})
+		return
+	}
+
+	rc, err := Store.Open(c.Request.Context(), video.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "视频文件不存在"})
+			return
+		}
+		log.Error("读取视频文件失败: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取视频文件失败"})
+		return
+	}
+	defer rc.Close()
+
+	// 上传的视频每次使用新的对象键，键名即可作为 ETag 供 If-Range 比较
+	name := path.Base(video.StorageKey)
+	c.Header("ETag", `"`+strings.TrimSuffix(name, path.Ext(name))+`"`)
+	c.Header("Cache-Control", "private")
+	if video.MimeType != "" {
+		c.Header("Content-Type", video.MimeType)
+	}
+
+	if rs, ok := rc.(io.ReadSeeker); ok {
+		http.ServeContent(c.Writer, c.Request, name, video.CreatedAt, rs)
+		return
+	}
+	// 不支持随机读取的存储后端只能整体返回
+	c.Header("Accept-Ranges", "none")
+	c.DataFromReader(http.StatusOK, video.Size, video.MimeType, rc, nil)
+}
+
+// handlePlayError 将视频播放相关错误映射为HTTP状态码
+func handlePlayError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, models.ErrVideoNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrNotEnrolled):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		log.Error("获取视频失败: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取视频失败"})
+	}
+}
@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mio/gin-example/models"
+	"mio/gin-example/subtitle"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// 字幕文件大小上限
+const maxSubtitleSize = 2 << 20
+
+// UploadVideoSubtitle 上传视频字幕
+// @Summary 上传字幕
+// @Description 支持 SRT 与 WebVTT，SRT 自动转换为 WebVTT；同一语言已有字幕时替换
+// @Tags 视频管理
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "视频ID"
+// @Param language formData string true "语言代码，如 zh-CN"
+// @Param label formData string false "显示名称"
+// @Param file formData file true "字幕文件"
+// @Success 201 {object} models.VideoSubtitle
+// @Router /admin/course/video/{id}/subtitle [post]
+func UploadVideoSubtitle(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的视频ID"})
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少字幕文件"})
+		return
+	}
+	if header.Size > maxSubtitleSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "字幕文件超过大小限制"})
+		return
+	}
+	f, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取字幕文件失败"})
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(f, maxSubtitleSize))
+	f.Close()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取字幕文件失败"})
+		return
+	}
+
+	vtt, err := subtitle.ToVTT(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name, err := models.NewUploadID()
+	if err != nil {
+		handleCourseError(c, err)
+		return
+	}
+	key := fmt.Sprintf("subtitles/%d/%s.vtt", id, name)
+	ctx := c.Request.Context()
+	if err := Store.Put(ctx, key, bytes.NewReader(vtt), "text/vtt; charset=utf-8"); err != nil {
+		handleCourseError(c, err)
+		return
+	}
+
+	sub := models.VideoSubtitle{
+		VideoID:    uint(id),
+		Language:   c.PostForm("language"),
+		Label:      c.PostForm("label"),
+		StorageKey: key,
+		URL:        Store.URL(key),
+	}
+	replaced, err := courseService().SetVideoSubtitle(&sub)
+	if err != nil {
+		Store.Delete(ctx, key)
+		handleCourseError(c, err)
+		return
+	}
+	if replaced != nil {
+		if err := Store.Delete(ctx, replaced.StorageKey); err != nil {
+			log.Errorf("删除旧字幕文件失败: %v", err)
+		}
+	}
+
+	c.JSON(http.StatusCreated, sub)
+}
+
+// DeleteVideoSubtitle 删除字幕
+// @Summary 删除字幕
+// @Tags 视频管理
+// @Param id path int true "字幕ID"
+// @Success 204
+// @Router /admin/course/video/subtitle/{id} [delete]
+func DeleteVideoSubtitle(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的字幕ID"})
+		return
+	}
+
+	sub, err := courseService().DeleteVideoSubtitle(uint(id))
+	if err != nil {
+		handleCourseError(c, err)
+		return
+	}
+	if err := Store.Delete(c.Request.Context(), sub.StorageKey); err != nil {
+		log.Errorf("删除字幕文件失败: %v", err)
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ChapterInput 章节标记
+type ChapterInput struct {
+	Start float64 `json:"start"`
+	Title string  `json:"title" binding:"required,max=100"`
+}
+
+// SetVideoChaptersRequest 设置章节请求体
+type SetVideoChaptersRequest struct {
+	Chapters []ChapterInput `json:"chapters" binding:"dive"`
+}
+
+// SetVideoChapters 设置视频章节，以请求中的章节替换原有全部章节
+// @Summary 设置章节
+// @Tags 视频管理
+// @Accept json
+// @Produce json
+// @Param id path int true "视频ID"
+// @Param body body SetVideoChaptersRequest true "章节列表"
+// @Success 200 {array} models.VideoChapter
+// @Router /admin/course/video/{id}/chapter [put]
+func SetVideoChapters(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的视频ID"})
+		return
+	}
+
+	var req SetVideoChaptersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	chapters := make([]models.VideoChapter, 0, len(req.Chapters))
+	for _, ch := range req.Chapters {
+		chapters = append(chapters, models.VideoChapter{Start: ch.Start, Title: ch.Title})
+	}
+	chapters, err = courseService().SetVideoChapters(uint(id), chapters)
+	if err != nil {
+		handleCourseError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, chapters)
+}
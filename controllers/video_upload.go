@@ -0,0 +1,561 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mio/gin-example/models"
+	"mio/gin-example/mp4"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// 允许上传的视频类型
+var allowedVideoTypes = []string{"video/mp4", "video/quicktime", "video/webm", "video/x-m4v"}
+
+// 可解析时长等信息的 MP4 容器类型
+var mp4VideoTypes = []string{"video/mp4", "video/quicktime", "video/x-m4v"}
+
+// 分片上传会话的有效期，每收到一个分片顺延
+const uploadSessionTTL = 24 * time.Hour
+
+// mimeSniffLen 识别文件类型时读取的文件头长度
+const mimeSniffLen = 3072
+
+var (
+	errUnsupportedVideoType = errors.New("不支持的视频格式，仅支持 MP4、MOV、WebM")
+	errVideoTooLarge        = errors.New("视频文件超过大小限制")
+	errInvalidVideoFile     = errors.New("视频文件已损坏或格式无效")
+)
+
+// sniffVideo 读取文件头识别视频类型，返回类型与包含已读文件头的完整读取器
+func sniffVideo(r io.Reader) (*mimetype.MIME, io.Reader, error) {
+	head := make([]byte, mimeSniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, nil, err
+	}
+	head = head[:n]
+
+	// 按识别结果及其父类型逐级匹配，如 M4V 属于 MP4 的子类型
+	for mt := mimetype.Detect(head); mt != nil; mt = mt.Parent() {
+		if mimetype.EqualsAny(mt.String(), allowedVideoTypes...) {
+			return mt, io.MultiReader(bytes.NewReader(head), r), nil
+		}
+	}
+	return nil, nil, errUnsupportedVideoType
+}
+
+// videoKey 生成上传视频的对象键，扩展名取自识别出的文件类型
+func videoKey(courseID uint, mt *mimetype.MIME) (string, error) {
+	id, err := models.NewUploadID()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("videos/%d/%s%s", courseID, id, mt.Extension()), nil
+}
+
+// limitedReader 读取超过 limit 字节时返回 errVideoTooLarge
+type limitedReader struct {
+	r     io.Reader
+	limit int64
+	n     int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.limit {
+		return n, errVideoTooLarge
+	}
+	return n, err
+}
+
+// UploadVideo 上传视频文件并创建视频
+// @Summary 上传视频
+// @Description multipart 表单上传，视频信息字段须位于 file 字段之前，文件以流的方式写入存储后端
+// @Tags 视频管理
+// @Accept multipart/form-data
+// @Produce json
+// @Param course_id formData int true "课程ID"
+// @Param unit_id formData int false "单元ID"
+// @Param title formData string true "视频标题"
+// @Param description formData string false "视频简介"
+// @Param is_mandatory formData bool false "是否必修"
+// @Param file formData file true "视频文件"
+// @Success 201 {object} models.Video
+// @Router /admin/course/video/upload [post]
+func UploadVideo(c *gin.Context) {
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请使用 multipart/form-data 上传"})
+		return
+	}
+
+	video := models.Video{IsMandatory: true}
+	fields := map[string]string{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "缺少视频文件"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "读取上传内容失败"})
+			return
+		}
+
+		if part.FormName() != "file" {
+			value, err := io.ReadAll(io.LimitReader(part, 64<<10))
+			part.Close()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "读取上传内容失败"})
+				return
+			}
+			fields[part.FormName()] = string(value)
+			continue
+		}
+
+		if err := applyVideoFields(&video, fields); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		service := courseService()
+		// 先校验视频信息，避免写入文件后才发现课程或标题有误
+		if err := service.ValidateVideo(&video); err != nil {
+			handleCourseError(c, err)
+			return
+		}
+
+		body := &limitedReader{r: part, limit: Conf.Upload.MaxSize}
+		if err := storeVideo(c, &video, body); err != nil {
+			handleUploadError(c, err)
+			return
+		}
+		video.Size = body.n
+		if err := service.CreateVideo(&video); err != nil {
+			Store.Delete(c.Request.Context(), video.StorageKey)
+			handleCourseError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, video)
+		return
+	}
+}
+
+// applyVideoFields 将表单字段写入视频信息
+func applyVideoFields(video *models.Video, fields map[string]string) error {
+	courseID, err := strconv.ParseUint(fields["course_id"], 10, 64)
+	if err != nil || courseID == 0 {
+		return errors.New("无效的课程ID")
+	}
+	video.CourseID = uint(courseID)
+	if s := fields["unit_id"]; s != "" {
+		unitID, err := strconv.ParseUint(s, 10, 64)
+		if err != nil || unitID == 0 {
+			return errors.New("无效的单元ID")
+		}
+		id := uint(unitID)
+		video.UnitID = &id
+	}
+	video.Title = fields["title"]
+	video.Description = fields["description"]
+	if s := fields["is_mandatory"]; s != "" {
+		mandatory, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.New("无效的必修标记")
+		}
+		video.IsMandatory = mandatory
+	}
+	return nil
+}
+
+// storeVideo 识别文件类型后写入存储后端，并记录对象键、地址与类型。
+// MP4 文件在写入的同时解析时长、尺寸与编码。
+func storeVideo(c *gin.Context, video *models.Video, r io.Reader) error {
+	mt, body, err := sniffVideo(r)
+	if err != nil {
+		return err
+	}
+	key, err := videoKey(video.CourseID, mt)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request.Context()
+	if !mimetype.EqualsAny(mt.String(), mp4VideoTypes...) {
+		if err := Store.Put(ctx, key, body, mt.String()); err != nil {
+			return err
+		}
+	} else {
+		info, err := putMP4(c, key, body, mt.String())
+		if err != nil {
+			return err
+		}
+		video.Duration = int(math.Round(info.Duration))
+		video.Width = info.Width
+		video.Height = info.Height
+		video.VideoCodec = info.VideoCodec
+		video.AudioCodec = info.AudioCodec
+		video.FastStart = &info.FastStart
+		if !info.FastStart {
+			log.Warnf("视频 %s 的 moov 位于文件末尾，在线播放需等待下载完成", key)
+		}
+	}
+
+	video.StorageKey = key
+	video.URL = Store.URL(key)
+	video.MimeType = mt.String()
+	return nil
+}
+
+// putMP4 写入存储后端的同时解析 MP4 信息，文件无法解析时删除已写入的文件
+func putMP4(c *gin.Context, key string, body io.Reader, contentType string) (*mp4.Info, error) {
+	type result struct {
+		info *mp4.Info
+		err  error
+	}
+	pr, pw := io.Pipe()
+	done := make(chan result, 1)
+	go func() {
+		info, err := mp4.Parse(pr)
+		// 解析结束后继续读空管道，避免阻塞写入
+		io.Copy(io.Discard, pr)
+		done <- result{info, err}
+	}()
+
+	err := Store.Put(c.Request.Context(), key, io.TeeReader(body, pw), contentType)
+	pw.CloseWithError(err)
+	res := <-done
+	if err != nil {
+		return nil, err
+	}
+	if res.err != nil {
+		Store.Delete(c.Request.Context(), key)
+		if errors.Is(res.err, mp4.ErrNotMP4) || errors.Is(res.err, mp4.ErrNoMoov) {
+			return nil, fmt.Errorf("%w: %v", errInvalidVideoFile, res.err)
+		}
+		return nil, res.err
+	}
+	return res.info, nil
+}
+
+// CreateUploadRequest 创建分片上传会话请求体
+type CreateUploadRequest struct {
+	CourseID    uint   `json:"course_id" binding:"required"`
+	UnitID      *uint  `json:"unit_id"`
+	Title       string `json:"title" binding:"required,max=100"`
+	Description string `json:"description"`
+	IsMandatory *bool  `json:"is_mandatory"`
+	FileName    string `json:"file_name" binding:"max=255"`
+	Size        int64  `json:"size" binding:"required,gt=0"`
+}
+
+// uploadLocks 同一会话的分片串行写入
+var uploadLocks sync.Map
+
+func lockUpload(id string) func() {
+	mu, _ := uploadLocks.LoadOrStore(id, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// uploadTempPath 分片上传暂存文件路径
+func uploadTempPath(id string) string {
+	return filepath.Join(Conf.Upload.TempDir, id+".part")
+}
+
+// CreateUpload 创建分片上传会话
+// @Summary 创建分片上传
+// @Description 给出视频信息与文件大小，返回会话ID与分片大小上限，之后按顺序上传分片
+// @Tags 视频管理
+// @Accept json
+// @Produce json
+// @Param body body CreateUploadRequest true "视频信息"
+// @Success 201 {object} models.UploadSession
+// @Router /admin/course/video/upload/session [post]
+func CreateUpload(c *gin.Context) {
+	var req CreateUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Size > Conf.Upload.MaxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": errVideoTooLarge.Error()})
+		return
+	}
+
+	video := models.Video{CourseID: req.CourseID, UnitID: req.UnitID, Title: req.Title}
+	if err := courseService().ValidateVideo(&video); err != nil {
+		handleCourseError(c, err)
+		return
+	}
+
+	id, err := models.NewUploadID()
+	if err != nil {
+		handleUploadError(c, err)
+		return
+	}
+	if err := os.MkdirAll(Conf.Upload.TempDir, 0o755); err != nil {
+		handleUploadError(c, err)
+		return
+	}
+	f, err := os.Create(uploadTempPath(id))
+	if err != nil {
+		handleUploadError(c, err)
+		return
+	}
+	f.Close()
+
+	session := models.UploadSession{
+		ID:          id,
+		UserID:      currentUserID(c),
+		CourseID:    req.CourseID,
+		UnitID:      req.UnitID,
+		Title:       video.Title,
+		Description: req.Description,
+		IsMandatory: req.IsMandatory == nil || *req.IsMandatory,
+		FileName:    req.FileName,
+		Size:        req.Size,
+		ExpiresAt:   time.Now().Add(uploadSessionTTL),
+	}
+	if err := DB.Create(&session).Error; err != nil {
+		os.Remove(uploadTempPath(id))
+		handleUploadError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"session": session, "chunk_size": Conf.Upload.ChunkSize})
+}
+
+// GetUpload 查询分片上传进度，用于断点续传
+// @Summary 查询分片上传进度
+// @Tags 视频管理
+// @Produce json
+// @Param id path string true "会话ID"
+// @Success 200 {object} models.UploadSession
+// @Router /admin/course/video/upload/session/{id} [get]
+func GetUpload(c *gin.Context) {
+	session, err := models.FindUploadSession(DB, c.Param("id"), currentUserID(c))
+	if err != nil {
+		handleUploadError(c, err)
+		return
+	}
+	c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	c.JSON(http.StatusOK, session)
+}
+
+// UploadChunk 上传分片
+// @Summary 上传分片
+// @Description 请求体为分片内容，Upload-Offset 头给出分片在文件中的起始位置，须等于已上传进度
+// @Tags 视频管理
+// @Accept application/octet-stream
+// @Produce json
+// @Param id path string true "会话ID"
+// @Param Upload-Offset header int true "分片起始位置"
+// @Success 200 {object} models.UploadSession
+// @Router /admin/course/video/upload/session/{id} [put]
+func UploadChunk(c *gin.Context) {
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 Upload-Offset"})
+		return
+	}
+
+	id := c.Param("id")
+	unlock := lockUpload(id)
+	defer unlock()
+
+	session, err := models.FindUploadSession(DB, id, currentUserID(c))
+	if err != nil {
+		handleUploadError(c, err)
+		return
+	}
+	if offset != session.Offset {
+		c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+		handleUploadError(c, models.ErrUploadOffsetMismatch)
+		return
+	}
+
+	limit := Conf.Upload.ChunkSize
+	if remain := session.Size - offset; remain < limit {
+		limit = remain
+	}
+
+	f, err := os.OpenFile(uploadTempPath(id), os.O_WRONLY, 0)
+	if err != nil {
+		handleUploadError(c, err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		handleUploadError(c, err)
+		return
+	}
+
+	// 多读一个字节以识别超出分片上限或文件大小的请求体
+	n, err := io.Copy(f, io.LimitReader(c.Request.Body, limit+1))
+	if err != nil {
+		handleUploadError(c, err)
+		return
+	}
+	if n > limit {
+		// 丢弃本次写入，进度保持不变，客户端可从原位置重传
+		f.Truncate(offset)
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "分片超过大小限制或超出文件大小"})
+		return
+	}
+
+	if err := models.AdvanceUploadOffset(DB, session, offset, offset+n, time.Now().Add(uploadSessionTTL)); err != nil {
+		handleUploadError(c, err)
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	c.JSON(http.StatusOK, session)
+}
+
+// CompleteUpload 完成分片上传，校验文件类型后写入存储后端并创建视频
+// @Summary 完成分片上传
+// @Tags 视频管理
+// @Produce json
+// @Param id path string true "会话ID"
+// @Success 201 {object} models.Video
+// @Router /admin/course/video/upload/session/{id}/complete [post]
+func CompleteUpload(c *gin.Context) {
+	id := c.Param("id")
+	unlock := lockUpload(id)
+	defer unlock()
+
+	session, err := models.FindUploadSession(DB, id, currentUserID(c))
+	if err != nil {
+		handleUploadError(c, err)
+		return
+	}
+	if session.Offset != session.Size {
+		c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+		handleUploadError(c, models.ErrUploadIncomplete)
+		return
+	}
+
+	f, err := os.Open(uploadTempPath(id))
+	if err != nil {
+		handleUploadError(c, err)
+		return
+	}
+	defer f.Close()
+
+	video := models.Video{
+		CourseID:    session.CourseID,
+		UnitID:      session.UnitID,
+		Title:       session.Title,
+		Description: session.Description,
+		IsMandatory: session.IsMandatory,
+		Size:        session.Size,
+	}
+	service := courseService()
+	if err := service.ValidateVideo(&video); err != nil {
+		handleCourseError(c, err)
+		return
+	}
+	if err := storeVideo(c, &video, f); err != nil {
+		if errors.Is(err, errUnsupportedVideoType) || errors.Is(err, errInvalidVideoFile) {
+			// 文件无法使用时会话已无继续的意义
+			removeUpload(session)
+		}
+		handleUploadError(c, err)
+		return
+	}
+	if err := service.CreateVideo(&video); err != nil {
+		Store.Delete(c.Request.Context(), video.StorageKey)
+		handleCourseError(c, err)
+		return
+	}
+
+	removeUpload(session)
+	c.JSON(http.StatusCreated, video)
+}
+
+// CancelUpload 取消分片上传并删除已上传的内容
+// @Summary 取消分片上传
+// @Tags 视频管理
+// @Param id path string true "会话ID"
+// @Success 204
+// @Router /admin/course/video/upload/session/{id} [delete]
+func CancelUpload(c *gin.Context) {
+	id := c.Param("id")
+	unlock := lockUpload(id)
+	defer unlock()
+
+	session, err := models.FindUploadSession(DB, id, currentUserID(c))
+	if err != nil {
+		handleUploadError(c, err)
+		return
+	}
+	removeUpload(session)
+	c.Status(http.StatusNoContent)
+}
+
+// removeUpload 删除上传会话及其暂存文件
+func removeUpload(session *models.UploadSession) {
+	if err := os.Remove(uploadTempPath(session.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Errorf("删除上传暂存文件失败: %v", err)
+	}
+	if err := DB.Delete(session).Error; err != nil {
+		log.Errorf("删除上传会话失败: %v", err)
+	}
+	uploadLocks.Delete(session.ID)
+}
+
+// StartUploadSweeper 定期清理过期的分片上传会话及其暂存文件
+func StartUploadSweeper(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			sessions, err := models.ExpiredUploadSessions(DB, now)
+			if err != nil {
+				log.Errorf("查询过期上传会话失败: %v", err)
+				continue
+			}
+			for i := range sessions {
+				unlock := lockUpload(sessions[i].ID)
+				removeUpload(&sessions[i])
+				unlock()
+			}
+			if len(sessions) > 0 {
+				log.Infof("清理过期上传会话 %d 个", len(sessions))
+			}
+		}
+	}()
+}
+
+// handleUploadError 将上传相关错误映射为HTTP状态码
+func handleUploadError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, models.ErrUploadNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrUploadOffsetMismatch),
+		errors.Is(err, models.ErrUploadIncomplete):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, errInvalidVideoFile):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, errUnsupportedVideoType):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	case errors.Is(err, errVideoTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	default:
+		log.Error("视频上传失败: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "视频上传失败"})
+	}
+}
@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"errors"
+	"mio/gin-example/models"
+	"mio/gin-example/wechat"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// WeChat 小程序接口客户端，未配置 AppID 时为空
+var WeChat *wechat.Client
+
+// WeChatLoginRequest 小程序登录请求体
+type WeChatLoginRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// WeChatLogin 小程序登录：用 wx.login 得到的 code 换取 openid，绑定或创建用户后签发令牌
+// @Summary 小程序登录
+// @Tags 登录
+// @Accept json
+// @Produce json
+// @Param body body WeChatLoginRequest true "wx.login 返回的 code"
+// @Router /login/wechat [post]
+func WeChatLogin(c *gin.Context) {
+	if WeChat == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "未配置小程序登录"})
+		return
+	}
+
+	var req WeChatLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, err := WeChat.Code2Session(c.Request.Context(), req.Code)
+	if err != nil {
+		if errors.Is(err, wechat.ErrInvalidCode) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		log.Error("微信登录失败: ", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "微信登录失败，请稍后重试"})
+		return
+	}
+
+	user, created, err := models.WeChatLogin(DB, session.OpenID, session.UnionID, session.SessionKey)
+	if err != nil {
+		log.Error("微信登录失败: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "登录失败"})
+		return
+	}
+	if !user.Status {
+		// 待审核的用户可以通过 /login/wechat/phone 绑定手机号，合并到预先导入的用户后登录
+		c.JSON(http.StatusForbidden, gin.H{"error": "账号未通过审核", "has_phone": user.Phone != nil})
+		return
+	}
+
+	resp, err := issueTokens(c, user)
+	if err != nil {
+		log.Error("生成令牌失败: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
+		return
+	}
+
+	resp["user_id"] = user.ID
+	resp["is_new"] = created
+	resp["has_phone"] = user.Phone != nil
+	c.JSON(http.StatusOK, resp)
+}
+
+// BindPhoneRequest 绑定手机号请求体，字段为小程序 getPhoneNumber 的返回值
+type BindPhoneRequest struct {
+	EncryptedData string `json:"encrypted_data" binding:"required"`
+	IV            string `json:"iv" binding:"required"`
+}
+
+// BindWeChatPhone 解密小程序获取的手机号并绑定到当前用户。
+// 手机号属于管理员预先导入的用户时合并到该用户，并为该用户签发新的访问令牌与刷新令牌。
+// @Summary 绑定微信手机号
+// @Tags 登录
+// @Accept json
+// @Produce json
+// @Param body body BindPhoneRequest true "getPhoneNumber 返回的加密数据"
+// @Router /user/phone/wechat [post]
+func BindWeChatPhone(c *gin.Context) {
+	if WeChat == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "未配置小程序登录"})
+		return
+	}
+
+	var req BindPhoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := DB.Select("id", "wx_session_key").First(&user, currentUserID(c)).Error; err != nil {
+		log.Error("查询用户失败: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "绑定手机号失败"})
+		return
+	}
+	if user.WxSessionKey == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请先通过微信登录"})
+		return
+	}
+
+	phone, err := decryptWeChatPhone(user.WxSessionKey, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	bindWeChatPhone(c, user.ID, phone, false)
+}
+
+// WeChatPhoneLoginRequest 待审核用户绑定手机号的请求体，code 为 wx.login 重新获取的登录凭证
+type WeChatPhoneLoginRequest struct {
+	Code string `json:"code" binding:"required"`
+	BindPhoneRequest
+}
+
+// WeChatPhoneLogin 微信登录并绑定手机号，不需要登录令牌。
+// 新建的微信用户在审核通过前无法获得令牌，通过此接口绑定手机号后，
+// 手机号属于管理员预先导入的用户时合并到该用户并登录，否则继续等待审核。
+// @Summary 微信手机号登录
+// @Tags 登录
+// @Accept json
+// @Produce json
+// @Param body body WeChatPhoneLoginRequest true "wx.login 返回的 code 与 getPhoneNumber 返回的加密数据"
+// @Router /login/wechat/phone [post]
+func WeChatPhoneLogin(c *gin.Context) {
+	if WeChat == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "未配置小程序登录"})
+		return
+	}
+
+	var req WeChatPhoneLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, err := WeChat.Code2Session(c.Request.Context(), req.Code)
+	if err != nil {
+		if errors.Is(err, wechat.ErrInvalidCode) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		log.Error("微信登录失败: ", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "微信登录失败，请稍后重试"})
+		return
+	}
+
+	phone, err := decryptWeChatPhone(session.SessionKey, req.BindPhoneRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, _, err := models.WeChatLogin(DB, session.OpenID, session.UnionID, session.SessionKey)
+	if err != nil {
+		log.Error("微信登录失败: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "登录失败"})
+		return
+	}
+
+	bindWeChatPhone(c, user.ID, phone, true)
+}
+
+// decryptWeChatPhone 解密 getPhoneNumber 返回的手机号
+func decryptWeChatPhone(sessionKey string, req BindPhoneRequest) (string, error) {
+	info, err := WeChat.DecryptPhone(sessionKey, req.EncryptedData, req.IV)
+	if err != nil {
+		return "", err
+	}
+	// 国内手机号按不带区号的形式保存，与管理员导入的手机号一致
+	if info.CountryCode != "" && info.CountryCode != "86" {
+		return info.PhoneNumber, nil
+	}
+	return info.PurePhoneNumber, nil
+}
+
+// bindWeChatPhone 绑定手机号并返回结果。合并到其他用户或 login 为 true 时签发绑定后用户的令牌，
+// 该用户未通过审核时返回 403。
+func bindWeChatPhone(c *gin.Context, userID uint, phone string, login bool) {
+	bound, merged, err := models.BindPhone(DB, userID, phone)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrPhoneBound), errors.Is(err, models.ErrPhoneTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case isUniqueViolation(err):
+			c.JSON(http.StatusConflict, gin.H{"error": models.ErrPhoneTaken.Error()})
+		default:
+			log.Error("绑定手机号失败: ", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "绑定手机号失败"})
+		}
+		return
+	}
+
+	resp := gin.H{"user_id": bound.ID, "phone": phone, "merged": merged}
+	if merged || login {
+		// 合并后原用户已删除，需要改用合并后用户的令牌
+		if !bound.Status {
+			c.JSON(http.StatusForbidden, gin.H{"error": "账号未通过审核"})
+			return
+		}
+		tokens, err := issueTokens(c, bound)
+		if err != nil {
+			log.Error("生成令牌失败: ", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
+			return
+		}
+		for k, v := range tokens {
+			resp[k] = v
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
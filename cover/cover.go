@@ -0,0 +1,330 @@
+// Package cover 生成课程默认封面：按课程名称确定背景色，在纯色背景上居中绘制课程名称，输出 PNG。
+package cover
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+
+	"mio/gin-example/storage"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// 封面尺寸（16:9）与版式
+const (
+	Width    = 640
+	Height   = 360
+	padding  = 48
+	maxLines = 3
+)
+
+// 字号从大到小尝试，直到课程名称能在 maxLines 行内排下
+var fontSizes = []float64{56, 48, 40, 34, 28}
+
+//go:embed fonts
+var embedded embed.FS
+
+// Renderer 封面绘制器。字体按顺序查找字形，前面的字体缺少某个字符时使用后面的字体
+type Renderer struct {
+	fonts []*sfnt.Font
+}
+
+// NewRenderer 加载嵌入的字体与 extraFont 指定的字体文件（可为空），最后追加内置的 Go 字体
+func NewRenderer(extraFont string) (*Renderer, error) {
+	var sources [][]byte
+
+	names, err := fs.Glob(embedded, "fonts/*")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		switch strings.ToLower(path.Ext(name)) {
+		case ".ttf", ".otf", ".ttc":
+			data, err := embedded.ReadFile(name)
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, data)
+		}
+	}
+
+	if extraFont != "" {
+		data, err := os.ReadFile(extraFont)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, data)
+	}
+	sources = append(sources, goregular.TTF)
+
+	r := &Renderer{}
+	for _, src := range sources {
+		collection, err := opentype.ParseCollection(src)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < collection.NumFonts(); i++ {
+			f, err := collection.Font(i)
+			if err != nil {
+				return nil, err
+			}
+			r.fonts = append(r.fonts, f)
+		}
+	}
+	return r, nil
+}
+
+// Color 由课程名称确定背景色：同名课程颜色固定，色相分散，饱和度与亮度保证白字清晰可读
+func Color(name string) color.RGBA {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	hue := float64(h.Sum32()%360) / 360
+	return hslToRGB(hue, 0.55, 0.42)
+}
+
+// Render 绘制封面并编码为 PNG
+func (r *Renderer) Render(name string) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, Width, Height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: Color(name)}, image.Point{}, draw.Src)
+
+	name = strings.Join(strings.Fields(name), " ")
+	if name != "" {
+		if err := r.drawText(img, name); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// styledRune 课程名称中的一个字符及绘制它的字体
+type styledRune struct {
+	r    rune
+	font int
+}
+
+func (r *Renderer) drawText(img *image.RGBA, text string) error {
+	var runes []styledRune
+	for _, ch := range text {
+		if sr, ok := r.lookup(ch); ok {
+			runes = append(runes, sr)
+		}
+	}
+	if len(runes) == 0 {
+		return nil
+	}
+
+	var (
+		faces []font.Face
+		lines [][]styledRune
+	)
+	for i, size := range fontSizes {
+		var err error
+		if faces, err = r.faces(size); err != nil {
+			return err
+		}
+		lines = wrap(runes, faces, fixed.I(Width-2*padding))
+		if len(lines) <= maxLines {
+			break
+		}
+		if i < len(fontSizes)-1 {
+			closeFaces(faces)
+		}
+	}
+	defer closeFaces(faces)
+	if len(lines) > maxLines {
+		// 最小字号仍排不下时截断，末尾以省略号结束
+		lines = lines[:maxLines]
+		last := lines[maxLines-1]
+		if ellipsis, ok := r.lookup('…'); ok {
+			last = append(last[:len(last)-1:len(last)-1], ellipsis)
+		}
+		lines[maxLines-1] = last
+	}
+
+	metrics := faces[0].Metrics()
+	lineHeight := metrics.Height * 12 / 10
+	top := (fixed.I(Height) - lineHeight.Mul(fixed.I(len(lines)))) / 2
+
+	drawer := font.Drawer{Dst: img, Src: image.White}
+	for i, line := range lines {
+		width := lineWidth(line, faces)
+		drawer.Dot = fixed.Point26_6{
+			X: (fixed.I(Width) - width) / 2,
+			Y: top + lineHeight.Mul(fixed.I(i)) + metrics.Ascent,
+		}
+		for _, sr := range line {
+			drawer.Face = faces[sr.font]
+			drawer.DrawString(string(sr.r))
+		}
+	}
+	return nil
+}
+
+// lookup 找到第一个包含该字符字形的字体
+func (r *Renderer) lookup(ch rune) (styledRune, bool) {
+	var buf sfnt.Buffer
+	for i, f := range r.fonts {
+		if idx, err := f.GlyphIndex(&buf, ch); err == nil && idx != 0 {
+			return styledRune{r: ch, font: i}, true
+		}
+	}
+	return styledRune{}, false
+}
+
+func (r *Renderer) faces(size float64) ([]font.Face, error) {
+	faces := make([]font.Face, len(r.fonts))
+	for i, f := range r.fonts {
+		face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+		if err != nil {
+			closeFaces(faces[:i])
+			return nil, err
+		}
+		faces[i] = face
+	}
+	return faces, nil
+}
+
+func closeFaces(faces []font.Face) {
+	for _, f := range faces {
+		f.Close()
+	}
+}
+
+// wrap 按宽度折行。中文逐字断行，拉丁字母组成的单词尽量整体换到下一行
+func wrap(runes []styledRune, faces []font.Face, maxWidth fixed.Int26_6) [][]styledRune {
+	var (
+		lines [][]styledRune
+		line  []styledRune
+	)
+	for _, sr := range runes {
+		if sr.r == ' ' && (len(line) == 0 || line[len(line)-1].r == ' ') {
+			continue
+		}
+		line = append(line, sr)
+		if len(line) == 1 || lineWidth(line, faces) <= maxWidth {
+			continue
+		}
+
+		// 超宽时从最后一个空格处断开；空格后不是完整的拉丁单词时在当前字符前断开
+		cut := len(line) - 1
+		if sr.r != ' ' && isWordRune(sr.r) {
+			for i := len(line) - 2; i > 0; i-- {
+				if line[i].r == ' ' {
+					cut = i + 1
+					break
+				}
+				if !isWordRune(line[i].r) {
+					break
+				}
+			}
+		}
+		lines = append(lines, trimSpace(line[:cut]))
+		line = append([]styledRune(nil), line[cut:]...)
+		if len(line) > 0 && line[0].r == ' ' {
+			line = line[1:]
+		}
+	}
+	if line = trimSpace(line); len(line) > 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// isWordRune 判断字符是否属于需要整体换行的单词（拉丁字母、数字等）
+func isWordRune(r rune) bool {
+	return r < 0x2E80 && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+func trimSpace(line []styledRune) []styledRune {
+	for len(line) > 0 && line[len(line)-1].r == ' ' {
+		line = line[:len(line)-1]
+	}
+	return line
+}
+
+func lineWidth(line []styledRune, faces []font.Face) fixed.Int26_6 {
+	var width fixed.Int26_6
+	for _, sr := range line {
+		adv, _ := faces[sr.font].GlyphAdvance(sr.r)
+		width += adv
+	}
+	return width
+}
+
+func hslToRGB(h, s, l float64) color.RGBA {
+	c := (1 - math.Abs(2*l-1)) * s
+	x := c * (1 - math.Abs(math.Mod(h*6, 2)-1))
+	m := l - c/2
+
+	var r, g, b float64
+	switch int(h * 6) {
+	case 0:
+		r, g, b = c, x, 0
+	case 1:
+		r, g, b = x, c, 0
+	case 2:
+		r, g, b = 0, c, x
+	case 3:
+		r, g, b = 0, x, c
+	case 4:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	return color.RGBA{
+		R: uint8(math.Round((r + m) * 255)),
+		G: uint8(math.Round((g + m) * 255)),
+		B: uint8(math.Round((b + m) * 255)),
+		A: 255,
+	}
+}
+
+// Generator 生成封面并保存到文件存储
+type Generator struct {
+	Renderer *Renderer
+	Storage  storage.Storage
+}
+
+// Key 返回课程名称对应的封面文件路径。同名课程使用同一地址，改名后地址随之变化
+func Key(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return "covers/" + hex.EncodeToString(sum[:8]) + ".png"
+}
+
+// Generate 绘制封面、保存并返回访问地址
+func (g *Generator) Generate(ctx context.Context, name string) (string, error) {
+	data, err := g.Renderer.Render(name)
+	if err != nil {
+		return "", err
+	}
+	key := Key(name)
+	if err := g.Storage.Put(ctx, key, bytes.NewReader(data), "image/png"); err != nil {
+		return "", err
+	}
+	return g.Storage.URL(key), nil
+}
@@ -0,0 +1,85 @@
+package cover
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestColorDeterministic(t *testing.T) {
+	if Color("安全生产培训") != Color("安全生产培训") {
+		t.Fatal("同名课程的封面颜色应相同")
+	}
+	if Color("安全生产培训") == Color("消防安全培训") {
+		t.Fatal("不同课程的封面颜色不应相同")
+	}
+}
+
+func TestRender(t *testing.T) {
+	r, err := NewRenderer("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{
+		"Go Programming",
+		"安全生产培训",
+		"",
+		strings.Repeat("Very long course title ", 20),
+	} {
+		data, err := r.Render(name)
+		if err != nil {
+			t.Fatalf("%q: %v", name, err)
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%q: %v", name, err)
+		}
+		if b := img.Bounds(); b.Dx() != Width || b.Dy() != Height {
+			t.Fatalf("%q: 封面尺寸为 %v", name, b)
+		}
+	}
+
+	// 嵌入的中文字体缺失时中文字符会被跳过，封面上只剩背景色
+	name := "安全生产培训"
+	if n := textPixels(t, r, name); n < 1000 {
+		t.Fatalf("%q: 只绘制了 %d 个文字像素", name, n)
+	}
+	for _, ch := range name {
+		if textPixels(t, r, string(ch)) == 0 {
+			t.Errorf("%q 没有绘制", ch)
+		}
+	}
+}
+
+// textPixels 统计封面中不是背景色的像素数
+func textPixels(t *testing.T, r *Renderer, name string) int {
+	t.Helper()
+	data, err := r.Render(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bg := Color(name)
+	n := 0
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if color.RGBAModel.Convert(img.At(x, y)) != bg {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestKeyStable(t *testing.T) {
+	if Key("课程") != Key("课程") || Key("课程") == Key("课程2") {
+		t.Fatal("封面地址应只由课程名称决定")
+	}
+}
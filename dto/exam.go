@@ -0,0 +1,150 @@
+// Package dto 定义面向考生的响应结构。考试进行中返回的结构不包含任何答案相关字段，
+// 答案与解析只通过 ReviewQuestion 在公开策略允许时返回。
+package dto
+
+import (
+	"encoding/json"
+	"mio/gin-example/models"
+	"strconv"
+	"time"
+)
+
+// Option 选择题选项
+type Option struct {
+	Key  string `json:"key"`
+	Text string `json:"text"`
+}
+
+// LearnerQuestion 作答中的题目，不含答案、候选答案、参考答案与解析
+type LearnerQuestion struct {
+	ID       uint     `json:"id"`
+	Type     string   `json:"type"`
+	Content  string   `json:"content"`
+	Score    float64  `json:"score"`
+	Options  []Option `json:"options,omitempty"`
+	Blanks   int      `json:"blanks,omitempty"`
+	Answered bool     `json:"answered"`
+}
+
+// LearnerAttempt 答题记录概要；未完成判分时不返回成绩
+type LearnerAttempt struct {
+	ID            uint       `json:"id"`
+	ExamID        uint       `json:"exam_id"`
+	ExamName      string     `json:"exam_name"`
+	Status        string     `json:"status"`
+	StartTime     time.Time  `json:"start_time"`
+	Deadline      time.Time  `json:"deadline"`
+	EndTime       *time.Time `json:"end_time,omitempty"`
+	AutoSubmitted bool       `json:"auto_submitted"`
+	Score         *float64   `json:"score,omitempty"`
+	IsPassed      *bool      `json:"is_passed,omitempty"`
+	// 剩余作答秒数，仅作答中返回
+	RemainingSeconds *int `json:"remaining_seconds,omitempty"`
+	// 可查看答案解析的时间，不公开时为空
+	ReviewAvailableAt *time.Time `json:"review_available_at,omitempty"`
+}
+
+// LearnerPaper 作答中的试卷及已保存的作答
+type LearnerPaper struct {
+	LearnerAttempt
+	Questions []LearnerQuestion        `json:"questions"`
+	Answers   map[uint]json.RawMessage `json:"answers"`
+}
+
+// ReviewQuestion 交卷后的逐题回顾，包含正确答案与解析
+type ReviewQuestion struct {
+	LearnerQuestion
+	UserAnswer    json.RawMessage     `json:"user_answer,omitempty"`
+	CorrectKeys   []string            `json:"correct_keys,omitempty"`
+	Candidates    map[string][]string `json:"candidates,omitempty"`
+	Reference     string              `json:"reference,omitempty"`
+	Analysis      string              `json:"analysis,omitempty"`
+	EarnedScore   float64             `json:"earned_score"`
+	IsCorrect     bool                `json:"is_correct"`
+	Comment       string              `json:"comment,omitempty"`
+	PendingReview bool                `json:"pending_review,omitempty"`
+}
+
+// ReviewPaper 交卷后的试卷回顾
+type ReviewPaper struct {
+	LearnerAttempt
+	Questions []ReviewQuestion `json:"questions"`
+}
+
+// NewLearnerAttempt 构造答题记录概要
+func NewLearnerAttempt(attempt *models.ExamAttempt, exam *models.Exam, now time.Time) LearnerAttempt {
+	view := LearnerAttempt{
+		ID:            attempt.ID,
+		ExamID:        attempt.ExamID,
+		ExamName:      exam.Name,
+		Status:        attempt.Status,
+		StartTime:     attempt.StartTime,
+		Deadline:      attempt.Deadline,
+		AutoSubmitted: attempt.AutoSubmitted,
+	}
+	switch attempt.Status {
+	case models.AttemptStatusInProgress:
+		remaining := int(attempt.Deadline.Sub(now).Seconds())
+		if remaining < 0 {
+			remaining = 0
+		}
+		view.RemainingSeconds = &remaining
+	case models.AttemptStatusGraded:
+		view.Score = &attempt.Score
+		view.IsPassed = &attempt.IsPassed
+		fallthrough
+	default:
+		view.EndTime = &attempt.EndTime
+		view.ReviewAvailableAt = exam.ReviewAvailableAt(attempt)
+	}
+	return view
+}
+
+// NewLearnerQuestion 按试卷中的选项顺序构造题目，points 为本场考试中的分值
+func NewLearnerQuestion(q *models.Question, item models.PaperItem, points float64) LearnerQuestion {
+	view := LearnerQuestion{
+		ID:      q.ID,
+		Type:    q.Type,
+		Content: q.Content,
+		Score:   points,
+	}
+	for _, key := range item.OptionOrder {
+		text, _ := q.Options[key].(string)
+		view.Options = append(view.Options, Option{Key: key, Text: text})
+	}
+	if q.Type == models.QuestionTypeFillBlank {
+		view.Blanks, _ = q.BlankCount()
+	}
+	return view
+}
+
+// NewReviewQuestion 构造包含答案与解析的逐题回顾
+func NewReviewQuestion(q *models.Question, item models.PaperItem, points float64, answer *models.ExamAnswer) ReviewQuestion {
+	view := ReviewQuestion{
+		LearnerQuestion: NewLearnerQuestion(q, item, points),
+		Analysis:        q.Analysis,
+	}
+
+	switch q.Type {
+	case models.QuestionTypeSingleChoice, models.QuestionTypeMultipleChoice:
+		view.CorrectKeys, _ = q.AnswerKeys()
+	case models.QuestionTypeFillBlank:
+		view.Candidates = make(map[string][]string)
+		for i := 1; i <= view.Blanks; i++ {
+			list, _ := q.BlankCandidates(i)
+			view.Candidates[strconv.Itoa(i)] = list
+		}
+	case models.QuestionTypeSubjective:
+		view.Reference = q.Reference
+	}
+
+	if answer != nil {
+		view.Answered = true
+		view.UserAnswer = json.RawMessage(answer.UserAnswer)
+		view.EarnedScore = answer.Score
+		view.IsCorrect = answer.IsCorrect
+		view.Comment = answer.Comment
+		view.PendingReview = q.Type == models.QuestionTypeSubjective && answer.ReviewedAt == nil
+	}
+	return view
+}
@@ -0,0 +1,34 @@
+package dto
+
+import (
+	"encoding/json"
+	"mio/gin-example/models"
+	"strings"
+	"testing"
+)
+
+func TestLearnerQuestionHidesAnswers(t *testing.T) {
+	q := &models.Question{
+		Type:       models.QuestionTypeSingleChoice,
+		Content:    "q",
+		Options:    models.JSONB{"A": "甲", "B": "乙"},
+		Answers:    models.JSONB{"keys": []any{"B"}},
+		Candidates: models.JSONB{"1": []any{"secret"}},
+		Reference:  "secret",
+		Analysis:   "secret",
+	}
+	view := NewLearnerQuestion(q, models.PaperItem{OptionOrder: []string{"B", "A"}}, 5)
+
+	b, err := json.Marshal(view)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, leak := range []string{"secret", "keys", "answers", "candidates", "reference", "analysis"} {
+		if strings.Contains(string(b), leak) {
+			t.Errorf("learner view leaks %q: %s", leak, b)
+		}
+	}
+	if len(view.Options) != 2 || view.Options[0].Key != "B" {
+		t.Errorf("options not in paper order: %+v", view.Options)
+	}
+}
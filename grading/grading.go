@@ -0,0 +1,350 @@
+// Package grading 实现考试自动判分：客观题按题型计分，并根据考试的分数规则确定每题分值。
+package grading
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mio/gin-example/models"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotSubjective   = errors.New("只有主观题需要人工判分")
+	ErrNotSubmitted    = errors.New("试卷尚未提交")
+	ErrScoreOutOfRange = errors.New("分数超出本题分值范围")
+	ErrAnswerNotFound  = errors.New("作答记录不存在")
+)
+
+// Options 判分选项，来自考试配置
+type Options struct {
+	Proportional     bool // 多选题按比例计分
+	IgnoreCase       bool // 填空题忽略大小写
+	IgnoreWhitespace bool // 填空题忽略首尾空白并合并连续空白
+	FoldWidth        bool // 填空题全角字符转半角
+}
+
+// OptionsForExam 读取考试的判分配置
+func OptionsForExam(exam *models.Exam) Options {
+	opts := Options{Proportional: exam.MultipleChoiceScoring == models.ScoringProportional}
+	for _, flag := range strings.Split(exam.BlankNormalize, ",") {
+		switch strings.TrimSpace(flag) {
+		case "case":
+			opts.IgnoreCase = true
+		case "space":
+			opts.IgnoreWhitespace = true
+		case "width":
+			opts.FoldWidth = true
+		}
+	}
+	return opts
+}
+
+// Result 单题判分结果
+type Result struct {
+	Correct bool
+	Score   float64
+	Manual  bool // 需要人工判分
+}
+
+// PointsFor 返回题目在本场考试中的分值：题库规则优先，其次题型规则，最后使用题目自身分值
+func PointsFor(q *models.Question, rules []models.ExamScoreRule) float64 {
+	var typeRule *models.ExamScoreRule
+	for i := range rules {
+		rule := &rules[i]
+		switch rule.RuleType {
+		case models.ScoreRuleBank:
+			if rule.TargetID == q.BankID {
+				return rule.ScorePerQuestion
+			}
+		case models.ScoreRuleType:
+			if rule.TargetType == q.Type && typeRule == nil {
+				typeRule = rule
+			}
+		}
+	}
+	if typeRule != nil {
+		return typeRule.ScorePerQuestion
+	}
+	return float64(q.Score)
+}
+
+// Grade 对单题作答判分，userAnswer 为 Question.NormalizeAnswer 产生的 JSON
+func Grade(q *models.Question, userAnswer string, points float64, opts Options) (Result, error) {
+	switch q.Type {
+	case models.QuestionTypeSingleChoice, models.QuestionTypeMultipleChoice:
+		correct, err := q.AnswerKeys()
+		if err != nil {
+			return Result{}, err
+		}
+		var given []string
+		if userAnswer != "" {
+			if err := json.Unmarshal([]byte(userAnswer), &given); err != nil {
+				return Result{}, fmt.Errorf("作答格式错误: %w", err)
+			}
+		}
+		proportional := opts.Proportional && q.Type == models.QuestionTypeMultipleChoice
+		return Choice(correct, given, points, proportional), nil
+	case models.QuestionTypeFillBlank:
+		n, err := q.BlankCount()
+		if err != nil {
+			return Result{}, err
+		}
+		candidates := make([][]string, n)
+		for i := range candidates {
+			if candidates[i], err = q.BlankCandidates(i + 1); err != nil {
+				return Result{}, err
+			}
+		}
+		var given []string
+		if userAnswer != "" {
+			if err := json.Unmarshal([]byte(userAnswer), &given); err != nil {
+				return Result{}, fmt.Errorf("作答格式错误: %w", err)
+			}
+		}
+		return FillBlank(candidates, given, points, opts), nil
+	case models.QuestionTypeSubjective:
+		return Result{Manual: true}, nil
+	default:
+		return Result{}, fmt.Errorf("无效的题目类型: %s", q.Type)
+	}
+}
+
+// Choice 选择题判分。按比例计分时选错任一项不得分，否则按选对的比例得分
+func Choice(correct, given []string, points float64, proportional bool) Result {
+	want := make(map[string]bool, len(correct))
+	for _, k := range correct {
+		want[k] = true
+	}
+
+	hit := 0
+	for _, k := range given {
+		if !want[k] {
+			return Result{}
+		}
+		hit++
+	}
+
+	if hit == len(want) && hit > 0 {
+		return Result{Correct: true, Score: points}
+	}
+	if proportional && hit > 0 {
+		return Result{Score: points * float64(hit) / float64(len(want))}
+	}
+	return Result{}
+}
+
+// FillBlank 填空题判分，每空等分，作答与任一候选答案归一化后相同即为正确
+func FillBlank(candidates [][]string, given []string, points float64, opts Options) Result {
+	if len(candidates) == 0 {
+		return Result{}
+	}
+
+	hit := 0
+	for i, list := range candidates {
+		if i >= len(given) {
+			break
+		}
+		answer := Normalize(given[i], opts)
+		if answer == "" {
+			continue
+		}
+		for _, c := range list {
+			if Normalize(c, opts) == answer {
+				hit++
+				break
+			}
+		}
+	}
+
+	return Result{
+		Correct: hit == len(candidates),
+		Score:   points * float64(hit) / float64(len(candidates)),
+	}
+}
+
+// Normalize 按选项归一化填空题文本
+func Normalize(s string, opts Options) string {
+	if opts.FoldWidth {
+		s = foldWidth(s)
+	}
+	if opts.IgnoreWhitespace {
+		s = strings.Join(strings.FieldsFunc(s, unicode.IsSpace), " ")
+	}
+	if opts.IgnoreCase {
+		s = strings.ToLower(s)
+	}
+	return s
+}
+
+// foldWidth 将全角 ASCII 字符与全角空格转为半角
+func foldWidth(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '　':
+			return ' '
+		case r >= '！' && r <= '～':
+			return r - 0xFEE0
+		}
+		return r
+	}, s)
+}
+
+// GradeAttempt 对已交卷的答题记录自动判分，可作为 models.AttemptHook 使用。
+// 有作答的主观题进入人工判分队列，记录处于待判分状态，直至全部判完才确定成绩。
+func GradeAttempt(tx *gorm.DB, attempt *models.ExamAttempt) error {
+	var exam models.Exam
+	if err := tx.Unscoped().First(&exam, attempt.ExamID).Error; err != nil {
+		return err
+	}
+	opts := OptionsForExam(&exam)
+	rules, err := models.ScoreRulesAt(tx, attempt.ExamID, attempt.StartTime)
+	if err != nil {
+		return err
+	}
+
+	var questions []models.Question
+	if err := tx.Unscoped().Where("id IN ?", attempt.Paper.QuestionIDs()).Find(&questions).Error; err != nil {
+		return err
+	}
+
+	var answers []models.ExamAnswer
+	if err := tx.Where("attempt_id = ?", attempt.ID).Find(&answers).Error; err != nil {
+		return err
+	}
+	byQuestion := make(map[uint]*models.ExamAnswer, len(answers))
+	for i := range answers {
+		byQuestion[answers[i].QuestionID] = &answers[i]
+	}
+
+	now := time.Now()
+	for i := range questions {
+		q := &questions[i]
+		answer, ok := byQuestion[q.ID]
+		if !ok {
+			continue
+		}
+
+		result, err := Grade(q, answer.UserAnswer, PointsFor(q, rules), opts)
+		if err != nil {
+			return fmt.Errorf("第 %d 题判分失败: %w", q.ID, err)
+		}
+		updates := map[string]any{
+			"is_correct": result.Correct,
+			"score":      result.Score,
+		}
+		if result.Manual {
+			// 作答无法解析时同样留给人工判分
+			var text string
+			if err := json.Unmarshal([]byte(answer.UserAnswer), &text); err != nil || strings.TrimSpace(text) != "" {
+				continue
+			}
+			// 空白作答无需人工判分
+			updates["reviewed_at"] = now
+		}
+		if err := tx.Model(answer).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+
+	return FinalizeAttempt(tx, attempt.ID)
+}
+
+// FinalizeAttempt 汇总各题得分；仍有未判的主观题时记录保持待判分状态，课程考试通过时更新课程完成情况
+func FinalizeAttempt(tx *gorm.DB, attemptID uint) error {
+	var attempt models.ExamAttempt
+	if err := tx.First(&attempt, attemptID).Error; err != nil {
+		return err
+	}
+	var exam models.Exam
+	// 考试删除后仍需完成待判分记录的判分
+	if err := tx.Unscoped().Select("id", "passing_score", "belongs_type", "belongs_id").First(&exam, attempt.ExamID).Error; err != nil {
+		return err
+	}
+
+	var pending int64
+	if err := tx.Model(&models.ExamAnswer{}).
+		Joins("JOIN questions ON questions.id = exam_answers.question_id").
+		Where("exam_answers.attempt_id = ? AND questions.type = ? AND exam_answers.reviewed_at IS NULL",
+			attempt.ID, models.QuestionTypeSubjective).
+		Count(&pending).Error; err != nil {
+		return err
+	}
+
+	var total float64
+	if err := tx.Model(&models.ExamAnswer{}).
+		Where("attempt_id = ?", attempt.ID).
+		Select("COALESCE(SUM(score), 0)").
+		Scan(&total).Error; err != nil {
+		return err
+	}
+
+	passed := pending == 0 && total >= float64(exam.PassingScore)
+	updates := map[string]any{"score": total, "is_passed": passed}
+	if pending > 0 {
+		updates["status"] = models.AttemptStatusPending
+	} else {
+		updates["status"] = models.AttemptStatusGraded
+		updates["graded_at"] = time.Now()
+	}
+	if err := tx.Model(&models.ExamAttempt{}).Where("id = ?", attempt.ID).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	// 课程考试通过后重新评估课程完成情况
+	if passed && exam.BelongsType == models.ExamBelongsCourse {
+		if _, err := models.NewCourseService(tx).EvaluateCompletion(attempt.UserID, exam.BelongsID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReviewAnswer 人工判定主观题得分；已判过的可重新判分，成绩随之重新汇总
+func ReviewAnswer(tx *gorm.DB, answerID, reviewerID uint, score float64, comment string) error {
+	var answer models.ExamAnswer
+	if err := tx.Preload("Question", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	}).First(&answer, answerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAnswerNotFound
+		}
+		return err
+	}
+	if answer.Question.Type != models.QuestionTypeSubjective {
+		return ErrNotSubjective
+	}
+
+	var attempt models.ExamAttempt
+	if err := tx.First(&attempt, answer.AttemptID).Error; err != nil {
+		return err
+	}
+	if attempt.Status != models.AttemptStatusPending && attempt.Status != models.AttemptStatusGraded {
+		return ErrNotSubmitted
+	}
+
+	rules, err := models.ScoreRulesAt(tx, attempt.ExamID, attempt.StartTime)
+	if err != nil {
+		return err
+	}
+	points := PointsFor(&answer.Question, rules)
+	if score < 0 || score > points {
+		return fmt.Errorf("%w: 0 - %v", ErrScoreOutOfRange, points)
+	}
+
+	now := time.Now()
+	if err := tx.Model(&answer).Updates(map[string]any{
+		"score":       score,
+		"is_correct":  score == points,
+		"reviewer_id": reviewerID,
+		"reviewed_at": now,
+		"comment":     comment,
+	}).Error; err != nil {
+		return err
+	}
+	return FinalizeAttempt(tx, attempt.ID)
+}
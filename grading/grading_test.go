@@ -0,0 +1,183 @@
+package grading
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mio/gin-example/models"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestChoice(t *testing.T) {
+	cases := []struct {
+		name         string
+		correct      []string
+		given        []string
+		proportional bool
+		want         Result
+	}{
+		{"single right", []string{"A"}, []string{"A"}, false, Result{Correct: true, Score: 10}},
+		{"single wrong", []string{"A"}, []string{"B"}, false, Result{}},
+		{"multi exact", []string{"A", "C"}, []string{"C", "A"}, false, Result{Correct: true, Score: 10}},
+		{"multi partial strict", []string{"A", "B", "C", "D"}, []string{"A"}, false, Result{}},
+		{"multi partial proportional", []string{"A", "B", "C", "D"}, []string{"A"}, true, Result{Score: 2.5}},
+		{"multi wrong option proportional", []string{"A", "B"}, []string{"A", "C"}, true, Result{}},
+		{"empty", []string{"A", "B"}, nil, true, Result{}},
+	}
+	for _, tc := range cases {
+		if got := Choice(tc.correct, tc.given, 10, tc.proportional); got != tc.want {
+			t.Errorf("%s: got %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestFillBlank(t *testing.T) {
+	candidates := [][]string{{"Beijing", "北京"}, {"TCP/IP"}}
+	loose := Options{IgnoreCase: true, IgnoreWhitespace: true, FoldWidth: true}
+
+	if got := FillBlank(candidates, []string{" beijing ", "ＴＣＰ／ＩＰ"}, 10, loose); !got.Correct || got.Score != 10 {
+		t.Errorf("loose match: got %+v", got)
+	}
+	if got := FillBlank(candidates, []string{"beijing", "TCP/IP"}, 10, Options{}); got.Correct || got.Score != 5 {
+		t.Errorf("strict match: got %+v", got)
+	}
+	if got := FillBlank(candidates, []string{"北京"}, 10, loose); got.Correct || got.Score != 5 {
+		t.Errorf("missing blank: got %+v", got)
+	}
+}
+
+func TestPointsFor(t *testing.T) {
+	q := &models.Question{BankID: 7, Type: models.QuestionTypeSingleChoice, Score: 10}
+	rules := []models.ExamScoreRule{
+		{RuleType: models.ScoreRuleType, TargetType: models.QuestionTypeSingleChoice, ScorePerQuestion: 2},
+		{RuleType: models.ScoreRuleBank, TargetID: 7, ScorePerQuestion: 5},
+	}
+	if got := PointsFor(q, rules); got != 5 {
+		t.Errorf("bank rule: got %v", got)
+	}
+	if got := PointsFor(q, rules[:1]); got != 2 {
+		t.Errorf("type rule: got %v", got)
+	}
+	if got := PointsFor(q, nil); got != 10 {
+		t.Errorf("fallback: got %v", got)
+	}
+}
+
+// openTestDB 创建内存数据库并建表，每个测试使用独立的数据库
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := models.AutoMigrate(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// submitAttempt 创建一场含单选题和主观题的课程考试，单选答对、主观题按 essayAnswer 作答后交卷
+func submitAttempt(t *testing.T, db *gorm.DB, svc *models.ExamService, essayAnswer any) (*models.ExamAttempt, models.Question) {
+	t.Helper()
+	user := models.User{Name: "张三", Role: "user"}
+	db.Create(&user)
+	course := models.Course{Name: "安全生产", EnrollmentCode: "c1"}
+	db.Create(&course)
+	bank := models.QuestionBank{Name: "安全题库", QuestionType: models.QuestionTypeSingleChoice}
+	db.Create(&bank)
+	choice := models.Question{BankID: bank.ID, Type: models.QuestionTypeSingleChoice, Content: "单选", Difficulty: 3, Score: 10,
+		Options: models.JSONB{"A": "对", "B": "错"}, Answers: models.JSONB{"keys": []string{"A"}}}
+	essay := models.Question{BankID: bank.ID, Type: models.QuestionTypeSubjective, Content: "简答", Difficulty: 3, Score: 10,
+		Answers: models.JSONB{}, Reference: "参考答案"}
+	db.Create(&choice)
+	db.Create(&essay)
+
+	now := time.Now()
+	exam := models.Exam{
+		Name: "结业考试", StartTime: now.Add(-time.Hour), EndTime: now.Add(time.Hour),
+		Duration: 30, MaxAttempts: 1, PassingScore: 30,
+		BelongsType: models.ExamBelongsCourse, BelongsID: course.ID,
+		QuestionConfigs: []models.ExamQuestionConfig{{QuestionBankID: bank.ID, Amount: 2}},
+		ScoreRules:      []models.ExamScoreRule{{RuleType: models.ScoreRuleBank, TargetID: bank.ID, ScorePerQuestion: 20}},
+	}
+	if err := svc.CreateExam(&exam); err != nil {
+		t.Fatal(err)
+	}
+	attempt, err := svc.StartAttempt(user.ID, exam.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	answer := func(v any) json.RawMessage {
+		b, _ := json.Marshal(v)
+		return b
+	}
+	if err := svc.SaveAnswers(user.ID, attempt.ID, []models.AnswerInput{
+		{QuestionID: choice.ID, Answer: answer([]string{"A"})},
+		{QuestionID: essay.ID, Answer: answer(essayAnswer)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if attempt, err = svc.SubmitAttempt(user.ID, attempt.ID); err != nil {
+		t.Fatal(err)
+	}
+	return attempt, essay
+}
+
+func TestGradeAfterExamDeleted(t *testing.T) {
+	db := openTestDB(t)
+	attempt, essay := submitAttempt(t, db, models.NewExamService(db).OnFinish(GradeAttempt), "安全第一")
+	examID := attempt.ExamID
+	if attempt.Status != models.AttemptStatusPending || attempt.Score != 20 {
+		t.Fatalf("交卷后 status=%s score=%v，客观题应按规则得 20 分并待人工判分", attempt.Status, attempt.Score)
+	}
+
+	// 开考后修改的规则不影响本次判分，随后考试及其配置被删除
+	db.Where("exam_id = ?", examID).Delete(&models.ExamScoreRule{})
+	db.Create(&models.ExamScoreRule{ExamID: examID, RuleType: models.ScoreRuleBank, TargetID: essay.BankID, ScorePerQuestion: 50})
+	db.Where("exam_id = ?", examID).Delete(&models.ExamScoreRule{})
+	db.Where("exam_id = ?", examID).Delete(&models.ExamQuestionConfig{})
+	db.Delete(&models.Exam{}, examID)
+
+	var essayAnswer models.ExamAnswer
+	db.Where("attempt_id = ? AND question_id = ?", attempt.ID, essay.ID).First(&essayAnswer)
+	if err := ReviewAnswer(db, essayAnswer.ID, 1, 25, ""); !errors.Is(err, ErrScoreOutOfRange) {
+		t.Fatalf("超出开考时规则分值应拒绝，got %v", err)
+	}
+	if err := ReviewAnswer(db, essayAnswer.ID, 1, 15, "要点不全"); err != nil {
+		t.Fatal(err)
+	}
+
+	db.First(attempt, attempt.ID)
+	if attempt.Status != models.AttemptStatusGraded || attempt.Score != 35 || !attempt.IsPassed {
+		t.Fatalf("判分后 status=%s score=%v passed=%v", attempt.Status, attempt.Score, attempt.IsPassed)
+	}
+}
+
+func TestGradeKeepsMalformedSubjectiveForReview(t *testing.T) {
+	db := openTestDB(t)
+	attempt, essay := submitAttempt(t, db, models.NewExamService(db), "安全第一")
+	db.Model(&models.ExamAnswer{}).Where("attempt_id = ? AND question_id = ?", attempt.ID, essay.ID).
+		Update("user_answer", `["安全第一"]`)
+
+	if err := GradeAttempt(db, attempt); err != nil {
+		t.Fatal(err)
+	}
+	var answer models.ExamAnswer
+	db.Where("attempt_id = ? AND question_id = ?", attempt.ID, essay.ID).First(&answer)
+	if answer.ReviewedAt != nil {
+		t.Fatal("无法解析的主观题作答不应自动判为已阅")
+	}
+	db.First(attempt, attempt.ID)
+	if attempt.Status != models.AttemptStatusPending {
+		t.Fatalf("status=%s，应等待人工判分", attempt.Status)
+	}
+}
@@ -0,0 +1,57 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"sort"
+)
+
+// JWK 公钥，格式见 RFC 7517
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	N         string `json:"n,omitempty"`   // RSA 模数
+	E         string `json:"e,omitempty"`   // RSA 指数
+	Curve     string `json:"crv,omitempty"` // OKP 曲线
+	X         string `json:"x,omitempty"`   // OKP 公钥
+}
+
+// JWKS 公钥集合
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS 返回仍可验证令牌的非对称密钥的公钥，HS256 密钥不公开
+func (m *Manager) JWKS() JWKS {
+	now := m.now()
+	set := JWKS{Keys: []JWK{}}
+	for _, k := range m.keys {
+		if k.retired(now) {
+			continue
+		}
+		jwk := JWK{KeyID: k.ID, Algorithm: k.Algorithm(), Use: "sig"}
+		switch pub := k.public.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = b64(pub.N.Bytes())
+			jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = b64(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+	return set
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
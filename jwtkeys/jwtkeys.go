@@ -0,0 +1,216 @@
+// Package jwtkeys 管理登录令牌的签名密钥。
+// 令牌头部带有 kid 标识签名密钥，支持 HS256、RS256 与 EdDSA；
+// 轮换密钥时新密钥用于签名，旧密钥在宽限期内仍可验证已签发的令牌。
+// 非对称密钥的公钥以 JWKS 格式公开，其他服务可据此独立验证令牌。
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnknownKey     = errors.New("未知的签名密钥")
+	ErrKeyRetired     = errors.New("签名密钥已停用")
+	ErrAlgorithmMatch = errors.New("令牌算法与签名密钥不符")
+)
+
+// 支持的算法
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+// Key 签名密钥
+type Key struct {
+	ID          string
+	VerifyUntil time.Time // 停止验证的时间，零值表示一直有效
+
+	method  jwt.SigningMethod
+	private any // 签名用，只用于验证的密钥为空
+	public  any // 验证用，HS256 与 private 相同
+}
+
+// KeyOptions 从配置加载密钥的参数
+type KeyOptions struct {
+	ID             string
+	Algorithm      string    // HS256/RS256/EdDSA
+	Secret         string    // HS256 密钥
+	PrivateKeyFile string    // RS256/EdDSA 私钥 PEM 文件，用于签名
+	PublicKeyFile  string    // RS256/EdDSA 公钥 PEM 文件，只用于验证的旧密钥可只提供公钥
+	VerifyUntil    time.Time // 停止验证的时间，零值表示一直有效
+}
+
+// NewHMACKey 创建 HS256 密钥。HS256 的验证密钥即签名密钥，不会出现在 JWKS 中
+func NewHMACKey(id string, secret []byte) *Key {
+	return &Key{ID: id, method: jwt.SigningMethodHS256, private: secret, public: secret}
+}
+
+// NewKey 由私钥创建签名密钥，算法按私钥类型确定
+func NewKey(id string, private any) (*Key, error) {
+	switch k := private.(type) {
+	case *rsa.PrivateKey:
+		return &Key{ID: id, method: jwt.SigningMethodRS256, private: k, public: &k.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &Key{ID: id, method: jwt.SigningMethodEdDSA, private: k, public: k.Public()}, nil
+	default:
+		return nil, fmt.Errorf("密钥 %s: 不支持的私钥类型 %T", id, private)
+	}
+}
+
+// NewPublicKey 由公钥创建只用于验证的密钥
+func NewPublicKey(id string, public any) (*Key, error) {
+	switch k := public.(type) {
+	case *rsa.PublicKey:
+		return &Key{ID: id, method: jwt.SigningMethodRS256, public: k}, nil
+	case ed25519.PublicKey:
+		return &Key{ID: id, method: jwt.SigningMethodEdDSA, public: k}, nil
+	default:
+		return nil, fmt.Errorf("密钥 %s: 不支持的公钥类型 %T", id, public)
+	}
+}
+
+// LoadKey 按配置加载密钥
+func LoadKey(opts KeyOptions) (*Key, error) {
+	if opts.ID == "" {
+		return nil, errors.New("签名密钥缺少 kid")
+	}
+
+	var (
+		key *Key
+		err error
+	)
+	switch opts.Algorithm {
+	case HS256:
+		if len(opts.Secret) < 32 {
+			return nil, fmt.Errorf("密钥 %s: HS256 密钥至少需要 32 字节", opts.ID)
+		}
+		key = NewHMACKey(opts.ID, []byte(opts.Secret))
+	case RS256, EdDSA:
+		key, err = loadPEM(opts)
+	default:
+		return nil, fmt.Errorf("密钥 %s: 不支持的算法 %q", opts.ID, opts.Algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	key.VerifyUntil = opts.VerifyUntil
+	return key, nil
+}
+
+func loadPEM(opts KeyOptions) (*Key, error) {
+	if opts.PrivateKeyFile != "" {
+		data, err := os.ReadFile(opts.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		var private any
+		if opts.Algorithm == RS256 {
+			private, err = jwt.ParseRSAPrivateKeyFromPEM(data)
+		} else {
+			private, err = jwt.ParseEdPrivateKeyFromPEM(data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("密钥 %s: 读取私钥失败: %w", opts.ID, err)
+		}
+		return NewKey(opts.ID, private)
+	}
+
+	if opts.PublicKeyFile == "" {
+		return nil, fmt.Errorf("密钥 %s: 需要私钥或公钥文件", opts.ID)
+	}
+	data, err := os.ReadFile(opts.PublicKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	var public any
+	if opts.Algorithm == RS256 {
+		public, err = jwt.ParseRSAPublicKeyFromPEM(data)
+	} else {
+		public, err = jwt.ParseEdPublicKeyFromPEM(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("密钥 %s: 读取公钥失败: %w", opts.ID, err)
+	}
+	return NewPublicKey(opts.ID, public)
+}
+
+// Algorithm 返回密钥的签名算法
+func (k *Key) Algorithm() string {
+	return k.method.Alg()
+}
+
+// Manager 持有全部可用密钥，用当前密钥签名，按令牌的 kid 选择密钥验证
+type Manager struct {
+	active  *Key
+	keys    map[string]*Key
+	methods []string
+	now     func() time.Time
+}
+
+// NewManager 创建密钥管理器，active 为签名使用的密钥 kid
+func NewManager(active string, keys ...*Key) (*Manager, error) {
+	m := &Manager{keys: make(map[string]*Key), now: time.Now}
+	seen := map[string]bool{}
+	for _, k := range keys {
+		if _, ok := m.keys[k.ID]; ok {
+			return nil, fmt.Errorf("重复的密钥 kid: %s", k.ID)
+		}
+		m.keys[k.ID] = k
+		if alg := k.Algorithm(); !seen[alg] {
+			seen[alg] = true
+			m.methods = append(m.methods, alg)
+		}
+	}
+
+	m.active = m.keys[active]
+	switch {
+	case m.active == nil:
+		return nil, fmt.Errorf("签名密钥 %q 不存在", active)
+	case m.active.private == nil:
+		return nil, fmt.Errorf("签名密钥 %q 缺少私钥", active)
+	case !m.active.VerifyUntil.IsZero():
+		return nil, fmt.Errorf("签名密钥 %q 不能设置停止验证时间", active)
+	}
+	return m, nil
+}
+
+// Sign 用当前密钥签发令牌，头部写入 kid
+func (m *Manager) Sign(claims jwt.Claims) (string, error) {
+	t := jwt.NewWithClaims(m.active.method, claims)
+	t.Header["kid"] = m.active.ID
+	return t.SignedString(m.active.private)
+}
+
+// Parse 验证令牌签名并解析到 claims，opts 可追加 iss、aud 等校验
+func (m *Manager) Parse(tokenString string, claims jwt.Claims, opts ...jwt.ParserOption) (*jwt.Token, error) {
+	opts = append([]jwt.ParserOption{jwt.WithValidMethods(m.methods)}, opts...)
+	return jwt.ParseWithClaims(tokenString, claims, m.keyFunc, opts...)
+}
+
+func (m *Manager) keyFunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	key, ok := m.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	// 算法必须与密钥一致，防止以公钥作为 HS256 密钥伪造令牌
+	if t.Method.Alg() != key.Algorithm() {
+		return nil, ErrAlgorithmMatch
+	}
+	if key.retired(m.now()) {
+		return nil, ErrKeyRetired
+	}
+	return key.public, nil
+}
+
+func (k *Key) retired(now time.Time) bool {
+	return !k.VerifyUntil.IsZero() && !now.Before(k.VerifyUntil)
+}
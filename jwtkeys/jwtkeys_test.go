@@ -0,0 +1,166 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestRotation(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKey, _ := NewKey("rsa-1", rsaKey)
+	newKey, _ := NewKey("ed-2", edKey)
+
+	before, err := NewManager("rsa-1", oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldToken, err := before.Sign(jwt.MapClaims{"sub": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 轮换：新密钥签名，旧密钥在宽限期内只用于验证
+	now := time.Now()
+	graced := *oldKey
+	graced.VerifyUntil = now.Add(time.Hour)
+	after, err := NewManager("ed-2", &graced, newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newToken, err := after.Sign(jwt.MapClaims{"sub": "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, token := range map[string]string{"旧密钥": oldToken, "新密钥": newToken} {
+		if _, err := after.Parse(token, jwt.MapClaims{}); err != nil {
+			t.Fatalf("%s签发的令牌验证失败: %v", name, err)
+		}
+	}
+	if ks := after.JWKS().Keys; len(ks) != 2 || ks[0].KeyID != "ed-2" || ks[1].KeyID != "rsa-1" {
+		t.Fatalf("JWKS = %+v", ks)
+	}
+
+	after.now = func() time.Time { return now.Add(2 * time.Hour) }
+	if _, err := after.Parse(oldToken, jwt.MapClaims{}); !errors.Is(err, ErrKeyRetired) {
+		t.Fatalf("宽限期后 err = %v", err)
+	}
+	if _, err := after.Parse(newToken, jwt.MapClaims{}); err != nil {
+		t.Fatal(err)
+	}
+	if ks := after.JWKS().Keys; len(ks) != 1 || ks[0].KeyID != "ed-2" {
+		t.Fatalf("宽限期后 JWKS = %+v", ks)
+	}
+}
+
+func TestParseIssuerAudience(t *testing.T) {
+	m, _ := NewManager("hs-1", NewHMACKey("hs-1", []byte("0123456789abcdef0123456789abcdef")))
+	token, err := m.Sign(jwt.MapClaims{"sub": "1", "iss": "gin-example", "aud": "gin-example-api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var claims jwt.RegisteredClaims
+	if _, err := m.Parse(token, &claims, jwt.WithIssuer("gin-example"), jwt.WithAudience("gin-example-api")); err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "1" {
+		t.Fatalf("sub = %q", claims.Subject)
+	}
+	if _, err := m.Parse(token, &jwt.RegisteredClaims{}, jwt.WithIssuer("other")); !errors.Is(err, jwt.ErrTokenInvalidIssuer) {
+		t.Fatalf("签发方不一致 err = %v", err)
+	}
+	if _, err := m.Parse(token, &jwt.RegisteredClaims{}, jwt.WithAudience("other")); !errors.Is(err, jwt.ErrTokenInvalidAudience) {
+		t.Fatalf("使用方不一致 err = %v", err)
+	}
+}
+
+func TestRejectForgedTokens(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := NewKey("rsa-1", rsaKey)
+	m, _ := NewManager("rsa-1", key, NewHMACKey("hs-1", []byte("0123456789abcdef0123456789abcdef")))
+
+	// 以 RSA 公钥作为 HS256 密钥伪造令牌
+	pub := x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "1"})
+	forged.Header["kid"] = "rsa-1"
+	s, _ := forged.SignedString(pub)
+	if _, err := m.Parse(s, jwt.MapClaims{}); !errors.Is(err, ErrAlgorithmMatch) {
+		t.Fatalf("算法混淆 err = %v", err)
+	}
+
+	noKid, _ := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "1"}).SignedString(rsaKey)
+	if _, err := m.Parse(noKid, jwt.MapClaims{}); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("缺少 kid err = %v", err)
+	}
+
+	// HS256 密钥不公开
+	if ks := m.JWKS().Keys; len(ks) != 1 || ks[0].KeyID != "rsa-1" {
+		t.Fatalf("JWKS = %+v", ks)
+	}
+	jwk := m.JWKS().Keys[0]
+	n, _ := base64.RawURLEncoding.DecodeString(jwk.N)
+	if new(big.Int).SetBytes(n).Cmp(rsaKey.N) != 0 || jwk.E != "AQAB" {
+		t.Fatalf("JWK = %+v", jwk)
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	dir := t.TempDir()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privDER, _ := x509.MarshalPKCS8PrivateKey(priv)
+	pubDER, _ := x509.MarshalPKIXPublicKey(pub)
+	privFile := filepath.Join(dir, "ed.pem")
+	pubFile := filepath.Join(dir, "ed.pub.pem")
+	os.WriteFile(privFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0o600)
+	os.WriteFile(pubFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0o600)
+
+	signing, err := LoadKey(KeyOptions{ID: "ed-1", Algorithm: EdDSA, PrivateKeyFile: privFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifying, err := LoadKey(KeyOptions{ID: "ed-1", Algorithm: EdDSA, PublicKeyFile: pubFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 其他服务只持有公钥即可验证
+	signer, _ := NewManager("ed-1", signing)
+	token, _ := signer.Sign(jwt.MapClaims{"sub": "1"})
+	verifier := &Manager{keys: map[string]*Key{"ed-1": verifying}, methods: []string{EdDSA}, now: time.Now}
+	if _, err := verifier.Parse(token, jwt.MapClaims{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewManager("ed-1", verifying); err == nil {
+		t.Fatal("只有公钥的密钥不能用于签名")
+	}
+
+	if _, err := LoadKey(KeyOptions{ID: "hs", Algorithm: HS256, Secret: "short"}); err == nil {
+		t.Fatal("过短的 HS256 密钥应当拒绝")
+	}
+}
@@ -1,12 +1,22 @@
 package main
 
 import (
+	"crypto/rand"
 	"errors"
 	"fmt"
+	"mio/gin-example/config"
 	"mio/gin-example/controllers"
+	"mio/gin-example/cover"
+	"mio/gin-example/jwtkeys"
 	"mio/gin-example/middlewares"
 	"mio/gin-example/models"
+	"mio/gin-example/storage"
+	"mio/gin-example/verifycode"
+	"mio/gin-example/videolink"
+	"mio/gin-example/wechat"
 	"os"
+	"path/filepath"
+	"time"
 	"unicode"
 
 	"github.com/gin-gonic/gin"
@@ -74,6 +84,93 @@ func InitLogrus() {
 	log.SetLevel(log.InfoLevel)
 }
 
+// newStorage 按配置创建文件存储后端
+func newStorage(conf config.StorageConfig) (storage.Storage, error) {
+	switch conf.Driver {
+	case "", "local":
+		return storage.NewLocal(conf.Dir, conf.BaseURL)
+	case "s3":
+		return storage.NewS3(storage.S3Options{
+			Endpoint:  conf.S3.Endpoint,
+			Region:    conf.S3.Region,
+			Bucket:    conf.S3.Bucket,
+			AccessKey: conf.S3.AccessKey,
+			SecretKey: conf.S3.SecretKey,
+			PublicURL: conf.S3.PublicURL,
+			PathStyle: conf.S3.PathStyle,
+		})
+	default:
+		return nil, fmt.Errorf("未知的存储后端: %s", conf.Driver)
+	}
+}
+
+// newKeyManager 按配置加载令牌签名密钥
+func newKeyManager(conf config.AuthConfig) (*jwtkeys.Manager, error) {
+	if len(conf.Keys) == 0 {
+		// 未配置密钥时随机生成，重启后已签发的访问令牌失效，客户端可用刷新令牌重新获取
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		log.Warn("未配置 auth.keys，使用随机密钥签发登录令牌")
+		return jwtkeys.NewManager("default", jwtkeys.NewHMACKey("default", secret))
+	}
+
+	keys := make([]*jwtkeys.Key, 0, len(conf.Keys))
+	for _, k := range conf.Keys {
+		opts := jwtkeys.KeyOptions{
+			ID:             k.ID,
+			Algorithm:      k.Algorithm,
+			Secret:         k.Secret,
+			PrivateKeyFile: k.PrivateKeyFile,
+			PublicKeyFile:  k.PublicKeyFile,
+		}
+		if k.VerifyUntil != nil {
+			opts.VerifyUntil = *k.VerifyUntil
+		}
+		key, err := jwtkeys.LoadKey(opts)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return jwtkeys.NewManager(conf.ActiveKey, keys...)
+}
+
+// newSMSCodes 按配置创建短信验证码服务
+func newSMSCodes(conf config.SMSConfig, db *gorm.DB) (*verifycode.Service, error) {
+	var store verifycode.Store
+	switch conf.Store {
+	case "", "memory":
+		store = verifycode.NewMemoryStore()
+	case "sql":
+		s, err := verifycode.NewSQLStore(db)
+		if err != nil {
+			return nil, err
+		}
+		store = s
+	default:
+		return nil, fmt.Errorf("未知的验证码存储: %s", conf.Store)
+	}
+
+	var sender verifycode.SMSSender
+	switch conf.Sender {
+	case "", "log":
+		sender = verifycode.LogSender{}
+	case "file":
+		sender = &verifycode.FileSender{Path: conf.SenderFile}
+	default:
+		return nil, fmt.Errorf("未知的短信发送渠道: %s", conf.Sender)
+	}
+
+	return verifycode.New(store, sender, verifycode.Options{
+		Length:      conf.CodeLength,
+		TTL:         time.Duration(conf.TTL) * time.Second,
+		Cooldown:    time.Duration(conf.Cooldown) * time.Second,
+		MaxAttempts: conf.MaxAttempts,
+	}), nil
+}
+
 func main() {
 	// dsn := "mio@tcp(146.56.220.190:3306)/test?charset=utf8mb4&parseTime=True&loc=Local"
 	dsn := "test.db"
@@ -84,6 +181,57 @@ func main() {
 	}
 	controllers.DB = db
 
+	conf, err := config.Load()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	controllers.Conf = conf
+
+	store, err := newStorage(conf.Storage)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	controllers.Store = store
+
+	linkKey := []byte(conf.Video.LinkSecret)
+	if len(linkKey) == 0 {
+		// 未配置密钥时随机生成，重启后已签发的播放链接失效
+		linkKey = make([]byte, 32)
+		if _, err := rand.Read(linkKey); err != nil {
+			fmt.Println(err)
+			return
+		}
+		log.Warn("未配置 video.link_secret，使用随机密钥签发播放链接")
+	}
+	controllers.Links = videolink.NewSigner(linkKey, time.Duration(conf.Video.LinkTTL)*time.Second)
+
+	if conf.WeChat.AppID != "" {
+		controllers.WeChat = wechat.NewClient(conf.WeChat.AppID, conf.WeChat.Secret, conf.WeChat.APIBase)
+	}
+
+	keys, err := newKeyManager(conf.Auth)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	controllers.Keys = keys
+
+	codes, err := newSMSCodes(conf.SMS, db)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	controllers.SMSCodes = codes
+
+	renderer, err := cover.NewRenderer(conf.Cover.FontFile)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	controllers.Covers = &cover.Generator{Renderer: renderer, Storage: store}
+
 	// r := gin.Default()
 	InitLogrus()
 	r := gin.New()
@@ -92,13 +240,21 @@ func main() {
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
 		v.RegisterValidation("password", passwordValidate)
 	}
+	if conf.Storage.Driver == "" || conf.Storage.Driver == "local" {
+		// 只公开课程封面与字幕，视频须通过签名的播放链接访问
+		r.Static(conf.Storage.BaseURL+"/covers", filepath.Join(conf.Storage.Dir, "covers"))
+		r.Static(conf.Storage.BaseURL+"/subtitles", filepath.Join(conf.Storage.Dir, "subtitles"))
+	}
 	r.GET("/ping", func(c *gin.Context) {
 		c.JSON(200, gin.H{
 			"message": "pong",
 		})
 	})
 
-	models.AutoMigrate(controllers.DB)
+	if err := models.AutoMigrate(controllers.DB); err != nil {
+		fmt.Println(err)
+		return
+	}
 
 	admin := r.Group("/v1/admin")
 	admin.Use(middlewares.AdminRequired)
@@ -117,18 +273,88 @@ func main() {
 	admin.GET("/course/:id", controllers.GetCourse)
 	admin.POST("/course", controllers.CreateCourse)
 	admin.PUT("/course/:id", controllers.UpdateCourse)
-	admin.DELETE("/course:id", controllers.DeleteCourse)
+	admin.DELETE("/course/:id", controllers.DeleteCourse)
+	admin.POST("/course/:id/restore", controllers.RestoreCourse)
+
+	admin.POST("/course/:id/cover", controllers.RegenerateCourseCover)
+
+	admin.GET("/course/:id/unit", controllers.GetCourseUnits)
+	admin.POST("/course/:id/unit", controllers.CreateCourseUnit)
+	admin.PUT("/course/:id/unit/order", controllers.ReorderCourseUnits)
+	admin.PUT("/course/unit/:id", controllers.UpdateCourseUnit)
+	admin.DELETE("/course/unit/:id", controllers.DeleteCourseUnit)
+	admin.PUT("/course/:id/video/unit", controllers.MoveCourseVideos)
 
 	admin.GET("/course/video", controllers.GetVideos)
 	admin.GET("/course/video/:id", controllers.GetVideo)
-	// admin.POST("/course/video", controllers.CreateVideo)
+	admin.POST("/course/video", controllers.CreateVideo)
 	admin.PUT("/course/video/:id", controllers.UpdateVideo)
+	admin.POST("/course/video/:id/subtitle", controllers.UploadVideoSubtitle)
+	admin.DELETE("/course/video/subtitle/:id", controllers.DeleteVideoSubtitle)
+	admin.PUT("/course/video/:id/chapter", controllers.SetVideoChapters)
+	admin.POST("/course/video/upload", controllers.UploadVideo)
+	admin.POST("/course/video/upload/session", controllers.CreateUpload)
+	admin.GET("/course/video/upload/session/:id", controllers.GetUpload)
+	admin.PUT("/course/video/upload/session/:id", controllers.UploadChunk)
+	admin.POST("/course/video/upload/session/:id/complete", controllers.CompleteUpload)
+	admin.DELETE("/course/video/upload/session/:id", controllers.CancelUpload)
 
 	admin.POST("/question_bank", controllers.CreateQuestionBank)
 	admin.GET("/question_bank", controllers.GetQuestionBanks)
+	admin.GET("/question_bank/:id", controllers.GetQuestionBank)
+	admin.PUT("/question_bank/:id", controllers.UpdateQuestionBank)
+	admin.DELETE("/question_bank/:id", controllers.DeleteQuestionBank)
+	admin.POST("/question_bank/:id/restore", controllers.RestoreQuestionBank)
+
+	admin.POST("/question_bank/:id/question", controllers.CreateQuestion)
+	admin.GET("/question_bank/:id/question", controllers.GetQuestions)
+	admin.GET("/question/:id", controllers.GetQuestion)
+	admin.PUT("/question/:id", controllers.UpdateQuestion)
+	admin.DELETE("/question/:id", controllers.DeleteQuestion)
+	admin.POST("/question/:id/restore", controllers.RestoreQuestion)
+
+	admin.POST("/exam", controllers.CreateExam)
+	admin.GET("/exam", controllers.GetExams)
+	admin.GET("/exam/:id", controllers.GetExam)
+	admin.PUT("/exam/:id", controllers.UpdateExam)
+	admin.DELETE("/exam/:id", controllers.DeleteExam)
+	admin.GET("/exam/attempt/:id/paper", controllers.GetAttemptPaper)
+	admin.GET("/exam/:id/grading", controllers.GetGradingQueue)
+	admin.PUT("/exam/answer/:id/grade", controllers.GradeAnswer)
+
+	user := r.Group("/v1")
+	user.Use(middlewares.UserRequired)
+
+	user.POST("/exam/:id/attempt", controllers.StartExamAttempt)
+	user.GET("/attempt/:id", controllers.GetExamAttempt)
+	user.PUT("/attempt/:id/answer", controllers.SaveExamAnswers)
+	user.POST("/attempt/:id/submit", controllers.SubmitExamAttempt)
+	user.GET("/attempt/:id/review", controllers.GetAttemptReview)
+
+	user.POST("/course/enroll", controllers.EnrollCourse)
+	user.POST("/video/:id/heartbeat", controllers.VideoHeartbeat)
+	user.GET("/video/:id/play", controllers.GetVideoPlayURL)
+	user.POST("/user/phone/wechat", controllers.BindWeChatPhone)
+	user.POST("/logout", controllers.Logout)
+	user.GET("/session", controllers.GetLoginSessions)
+	user.DELETE("/session/:id", controllers.DeleteLoginSession)
+
+	// 播放器无法携带登录令牌，由链接签名校验身份
+	r.GET("/v1/video/:id/stream", controllers.StreamVideo)
+	r.HEAD("/v1/video/:id/stream", controllers.StreamVideo)
+
+	controllers.StartAttemptSweeper(time.Minute)
+	controllers.StartUploadSweeper(time.Hour)
+	controllers.StartSMSCodeSweeper(10 * time.Minute)
+	controllers.StartSessionSweeper(time.Hour)
 
 	// r.POST("/v1/signup", controllers.Signup)
 	r.POST("/v1/login", controllers.HandleLogin)
-	r.GET("/course/:id", controllers.GetCourse)
+	r.POST("/v1/login/code", controllers.SendLoginCode)
+	r.POST("/v1/login/wechat", controllers.WeChatLogin)
+	r.POST("/v1/login/wechat/phone", controllers.WeChatPhoneLogin)
+	r.POST("/v1/token/refresh", controllers.RefreshToken)
+	r.GET("/.well-known/jwks.json", controllers.GetJWKS)
+	r.GET("/course/:id", controllers.GetPublicCourse)
 	r.Run() // 监听并在 0.0.0.0:8080 上启动服务
 }
@@ -14,55 +14,88 @@ import (
 )
 
 func AdminRequired(c *gin.Context) {
+	claims, ok := authenticate(c)
+	if !ok {
+		return
+	}
+	if claims.Rol != "admin" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "permission denied",
+		})
+		log.Errorln("permission denied", claims.Rol)
+		return
+	}
+	c.Next()
+}
+
+// UserRequired 校验登录令牌，并将当前用户写入上下文
+func UserRequired(c *gin.Context) {
+	if _, ok := authenticate(c); !ok {
+		return
+	}
+	c.Next()
+}
+
+func authenticate(c *gin.Context) (*CustomClaims, bool) {
 	token := c.Request.Header.Get("token")
 	claims, err := parseToken(token)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err,
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
 		})
 		log.Errorln(err)
-		return
+		return nil, false
 	}
 	ok, err := CheckVersion(claims)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err,
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
 		})
 		log.Errorln(err)
-		return
+		return nil, false
 	}
 	if !ok {
-		c.JSON(http.StatusBadRequest, gin.H{
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 			"error": "invalid login token",
 		})
 		log.Errorln("bad token: ", token)
-		return
+		return nil, false
+	}
+	active, err := models.IsSessionActive(controllers.DB, claims.Sid, claims.Sub)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		log.Errorln(err)
+		return nil, false
+	}
+	if !active {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": "login session revoked",
+		})
+		log.Errorln("revoked session: ", claims.Sid)
+		return nil, false
 	}
 	if IsExpired(claims) {
-		c.JSON(http.StatusBadRequest, gin.H{
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 			"error": "token is outdate",
 		})
 		log.Errorln("bad token", token)
-		return
+		return nil, false
 	}
-	if claims.Rol != "admin" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "permission denied",
-		})
-		log.Errorln("permission denied", claims.Rol)
-		return
-	}
-	c.Next()
+	c.Set(controllers.UserIDKey, claims.Sub)
+	c.Set(controllers.RoleKey, claims.Rol)
+	c.Set(controllers.SessionIDKey, claims.Sid)
+	return claims, true
 }
 
 func CheckVersion(claims *CustomClaims) (bool, error) {
 	user := models.FindUserByID(controllers.DB, claims.Sub)
-	_ver, err := strconv.Atoi(claims.Ver)
-	if err != nil {
-		return false, err
+	if user.ID == 0 || !user.Status {
+		return false, nil
 	}
-	ver := uint(_ver)
-	return ver <= user.TokenVersion, nil
+	// 修改密码等操作会提升用户的令牌版本，之前签发的令牌全部失效
+	return claims.Ver == user.TokenVersion, nil
 }
 
 func IsExpired(claims *CustomClaims) bool {
@@ -71,24 +104,21 @@ func IsExpired(claims *CustomClaims) bool {
 	return time.Now().After(exp)
 }
 
+// CustomClaims 访问令牌的声明。sub 为字符串形式的用户ID，iss、aud 为签发方与使用方，
+// 由 RegisteredClaims 解析
 type CustomClaims struct {
-	Sub uint    `json:"sub"`
+	Sub uint   `json:"-"` // 由 sub 解析出的用户ID
 	Exp int64  `json:"exp"`
 	Rol string `json:"rol"`
-	Ver string `json:"ver"`
+	Ver uint   `json:"ver"`
+	Sid string `json:"sid"` // 登录会话ID
 	jwt.RegisteredClaims
 }
 
 func parseToken(tokenString string) (*CustomClaims, error) {
-	// 解析Token
-	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// 验证签名方法
-		if token.Method != jwt.SigningMethodHS256 {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		// 返回密钥
-		return []byte("ygredgds"), nil
-	})
+	// 解析Token，按头部的 kid 选择验证密钥
+	token, err := controllers.Keys.Parse(tokenString, &CustomClaims{},
+		jwt.WithIssuer(controllers.Conf.Auth.Issuer), jwt.WithAudience(controllers.Conf.Auth.Audience))
 
 	// 处理解析错误
 	if err != nil {
@@ -97,11 +127,16 @@ func parseToken(tokenString string) (*CustomClaims, error) {
 	}
 
 	// 验证Claims
-	if claims, ok := token.Claims.(*CustomClaims); ok && token.Valid {
-		return claims, nil
-	} else {
+	claims, ok := token.Claims.(*CustomClaims)
+	if !ok || !token.Valid {
 		return nil, fmt.Errorf("invalid token")
 	}
+	id, err := strconv.ParseUint(claims.Subject, 10, 0)
+	if err != nil || id == 0 {
+		return nil, fmt.Errorf("invalid token subject")
+	}
+	claims.Sub = uint(id)
+	return claims, nil
 }
 
 func Logger(c *gin.Context) {
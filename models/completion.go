@@ -0,0 +1,115 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CompletionStatus 课程完成情况
+type CompletionStatus struct {
+	CourseID        uint  `json:"course_id"`
+	MandatoryVideos int64 `json:"mandatory_videos"` // 必修视频数
+	CompletedVideos int64 `json:"completed_videos"` // 已看完的必修视频数
+	Exams           int64 `json:"exams"`            // 课程考试数
+	PassedExams     int64 `json:"passed_exams"`     // 已通过的考试数
+	Progress        uint  `json:"progress"`         // 学习进度（0-100）
+	IsCompleted     bool  `json:"is_completed"`
+}
+
+// EvaluateCompletion 汇总必修视频与课程考试的完成情况，更新报名记录中的学习进度（只增不减）。
+// 全部必修视频看完且全部课程考试通过时标记课程完成，课程完成人数只在首次完成时增加。
+// 用户未报名该课程时返回 nil。由视频观看与考试判分的事务调用时，服务应基于该事务创建。
+func (s *CourseService) EvaluateCompletion(userID, courseID uint) (*CompletionStatus, error) {
+	var status *CompletionStatus
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		status, err = NewCourseService(tx).evaluateCompletion(userID, courseID)
+		return err
+	})
+	return status, err
+}
+
+func (s *CourseService) evaluateCompletion(userID, courseID uint) (*CompletionStatus, error) {
+	var enrollment Enrollment
+	if err := s.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND course_id = ?", userID, courseID).
+		Order("id ASC").First(&enrollment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	status, err := completionStatus(s.db, userID, courseID)
+	if err != nil {
+		return nil, err
+	}
+	if enrollment.IsCompleted {
+		// 已完成的课程不因之后新增的必修内容而回退
+		status.Progress = 100
+		status.IsCompleted = true
+		return status, nil
+	}
+
+	if !status.IsCompleted {
+		if status.Progress <= enrollment.Progress {
+			status.Progress = enrollment.Progress
+			return status, nil
+		}
+		return status, s.db.Model(&enrollment).Update("progress", status.Progress).Error
+	}
+
+	result := s.db.Model(&Enrollment{}).
+		Where("id = ? AND is_completed = ?", enrollment.ID, false).
+		Updates(map[string]any{"progress": 100, "is_completed": true, "completed_at": time.Now()})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return status, nil
+	}
+	return status, s.IncrementCompletion(courseID)
+}
+
+// completionStatus 统计必修视频观看与课程考试通过情况。课程没有任何必修内容时不视为完成
+func completionStatus(db *gorm.DB, userID, courseID uint) (*CompletionStatus, error) {
+	status := &CompletionStatus{CourseID: courseID}
+
+	if err := db.Model(&Video{}).
+		Where("course_id = ? AND is_mandatory = ?", courseID, true).
+		Count(&status.MandatoryVideos).Error; err != nil {
+		return nil, err
+	}
+	completed, err := completedMandatoryVideos(db, userID, courseID)
+	if err != nil {
+		return nil, err
+	}
+	status.CompletedVideos = completed
+
+	if err := db.Model(&Exam{}).
+		Where("belongs_type = ? AND belongs_id = ?", ExamBelongsCourse, courseID).
+		Count(&status.Exams).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&ExamAttempt{}).
+		Joins("JOIN exams ON exams.id = exam_attempts.exam_id").
+		Where("exams.belongs_type = ? AND exams.belongs_id = ? AND exams.deleted_at IS NULL", ExamBelongsCourse, courseID).
+		Where("exam_attempts.user_id = ? AND exam_attempts.status = ? AND exam_attempts.is_passed = ?",
+			userID, AttemptStatusGraded, true).
+		Distinct("exam_attempts.exam_id").
+		Count(&status.PassedExams).Error; err != nil {
+		return nil, err
+	}
+
+	total := status.MandatoryVideos + status.Exams
+	if total == 0 {
+		return status, nil
+	}
+	done := status.CompletedVideos + status.PassedExams
+	status.Progress = uint(done * 100 / total)
+	status.IsCompleted = done == total
+	return status, nil
+}
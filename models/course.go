@@ -2,8 +2,12 @@ package models
 
 import (
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Course struct {
@@ -16,6 +20,9 @@ type Course struct {
 	IsOpen          bool         `gorm:"default:false"`
 	EnrollmentCount uint         `gorm:"default:0"`
 	CompletionCount uint         `gorm:"default:0"`
+	MaxEnrollments  uint         `gorm:"default:0"` // 报名人数上限，0 表示不限
+	EnrollStartAt   *time.Time   // 报名开始时间，为空表示不限
+	EnrollEndAt     *time.Time   // 报名截止时间，为空表示不限
 	Videos          []Video      `gorm:"foreignKey:CourseID"`
 	// 移除 ExamID，改为在 Exam 中关联 Course
 }
@@ -28,55 +35,221 @@ type CourseUnit struct {
 	CourseID    uint   `gorm:"index:idx_course_order,unique"`
 }
 
+// CoverGenerator 根据课程名称生成默认封面，返回封面地址
+type CoverGenerator func(name string) (string, error)
+
 type CourseService struct {
-	db *gorm.DB
+	db    *gorm.DB
+	cover CoverGenerator
 }
 
 func NewCourseService(db *gorm.DB) *CourseService {
 	return &CourseService{db: db}
 }
 
-// CreateCourse 创建课程
+// WithCoverGenerator 设置默认封面的生成方式，未设置时新建课程不生成封面
+func (s *CourseService) WithCoverGenerator(gen CoverGenerator) *CourseService {
+	s.cover = gen
+	return s
+}
+
+var (
+	ErrCourseNameExists     = errors.New("课程名称已存在")
+	ErrEnrollmentCodeExists = errors.New("报名码已被其他课程使用")
+	ErrInvalidCourse        = errors.New("课程信息无效")
+	ErrCoverUnavailable     = errors.New("未配置默认封面生成")
+)
+
+// 新建课程时自动创建的第一单元
+const (
+	defaultUnitName        = "课程导论"
+	defaultUnitDescription = "课程介绍和基本要求"
+)
+
+// Validate 校验课程基本信息
+func (c *Course) Validate() error {
+	if strings.TrimSpace(c.Name) == "" {
+		return fmt.Errorf("%w: 课程名称不能为空", ErrInvalidCourse)
+	}
+	if strings.TrimSpace(c.EnrollmentCode) == "" {
+		return fmt.Errorf("%w: 报名码不能为空", ErrInvalidCourse)
+	}
+	if c.EnrollStartAt != nil && c.EnrollEndAt != nil && !c.EnrollEndAt.After(*c.EnrollStartAt) {
+		return fmt.Errorf("%w: 报名截止时间必须晚于开始时间", ErrInvalidCourse)
+	}
+	return nil
+}
+
+// CourseUpdate 课程的可修改字段，为空的字段保持不变
+type CourseUpdate struct {
+	Name           *string
+	Description    *string
+	CoverImage     *string
+	EnrollmentCode *string
+	IsOpen         *bool
+	MaxEnrollments *uint
+	EnrollStartAt  *time.Time
+	EnrollEndAt    *time.Time
+}
+
+// GetCourse 获取课程及其单元
+func (s *CourseService) GetCourse(courseID uint) (*Course, error) {
+	var course Course
+	if err := s.db.Preload("Units", OrderUnits).First(&course, courseID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCourseNotFound
+		}
+		return nil, err
+	}
+	return &course, nil
+}
+
+// CreateCourse 创建课程。未指定封面时生成默认封面；未指定单元时创建默认的第一单元，
+// 指定的单元未设置顺序时按给出的先后依次编号。
 func (s *CourseService) CreateCourse(course *Course) error {
-	// 检查课程名称唯一性
-	var count int64
-	s.db.Model(&Course{}).Where("name = ?", course.Name).Count(&count)
-	if count > 0 {
-		return errors.New("课程名称已存在")
+	course.Name = strings.TrimSpace(course.Name)
+	course.EnrollmentCode = strings.TrimSpace(course.EnrollmentCode)
+	if err := course.Validate(); err != nil {
+		return err
 	}
 
-	// 自动生成纯色封面（示例逻辑）
-	if course.CoverImage == "" {
-		defaultCover := generateDefaultCover(course.Name)
-		course.CoverImage = defaultCover
+	if len(course.Units) == 0 {
+		course.Units = []CourseUnit{{
+			UnitName:    defaultUnitName,
+			Description: defaultUnitDescription,
+			Order:       1,
+		}}
+	}
+	if err := normalizeUnitOrder(course.Units); err != nil {
+		return err
 	}
 
 	return s.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Create(course).Error; err != nil {
+		if err := checkCourseUnique(tx, 0, course.Name, course.EnrollmentCode); err != nil {
 			return err
 		}
 
-		// 创建默认第一单元
-		if len(course.Units) == 0 {
-			defaultUnit := CourseUnit{
-				UnitName:    "课程导论",
-				Description: "课程介绍和基本要求",
-				Order:       1,
+		// 未上传封面时生成纯色背景加课程名称的默认封面
+		if course.CoverImage == "" && s.cover != nil {
+			url, err := s.cover(course.Name)
+			if err != nil {
+				return fmt.Errorf("生成默认封面失败: %w", err)
 			}
-			if err := tx.Create(&defaultUnit).Error; err != nil {
-				return err
+			course.CoverImage = url
+		}
+		return tx.Create(course).Error
+	})
+}
+
+// UpdateCourse 修改课程信息
+func (s *CourseService) UpdateCourse(courseID uint, update CourseUpdate) (*Course, error) {
+	var course Course
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&course, courseID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCourseNotFound
 			}
+			return err
 		}
-		return nil
+
+		if update.Name != nil {
+			course.Name = strings.TrimSpace(*update.Name)
+		}
+		if update.Description != nil {
+			course.Description = *update.Description
+		}
+		if update.CoverImage != nil {
+			course.CoverImage = *update.CoverImage
+		}
+		if update.EnrollmentCode != nil {
+			course.EnrollmentCode = strings.TrimSpace(*update.EnrollmentCode)
+		}
+		if update.IsOpen != nil {
+			course.IsOpen = *update.IsOpen
+		}
+		if update.MaxEnrollments != nil {
+			course.MaxEnrollments = *update.MaxEnrollments
+		}
+		if update.EnrollStartAt != nil {
+			course.EnrollStartAt = update.EnrollStartAt
+		}
+		if update.EnrollEndAt != nil {
+			course.EnrollEndAt = update.EnrollEndAt
+		}
+		if err := course.Validate(); err != nil {
+			return err
+		}
+		if err := checkCourseUnique(tx, course.ID, course.Name, course.EnrollmentCode); err != nil {
+			return err
+		}
+
+		return tx.Omit(clause.Associations).Save(&course).Error
 	})
+	if err != nil {
+		return nil, err
+	}
+	return &course, nil
+}
+
+// checkCourseUnique 检查课程名称与报名码未被其他课程使用
+func checkCourseUnique(tx *gorm.DB, excludeID uint, name, code string) error {
+	var count int64
+	if err := tx.Model(&Course{}).Where("name = ? AND id <> ?", name, excludeID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrCourseNameExists
+	}
+	if err := tx.Model(&Course{}).Where("enrollment_code = ? AND id <> ?", code, excludeID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrEnrollmentCodeExists
+	}
+	return nil
+}
+
+// normalizeUnitOrder 校验单元名称与顺序；均未设置顺序时按先后编号为 1..n
+func normalizeUnitOrder(units []CourseUnit) error {
+	unset := true
+	for _, u := range units {
+		if strings.TrimSpace(u.UnitName) == "" {
+			return fmt.Errorf("%w: 单元名称不能为空", ErrInvalidCourse)
+		}
+		if u.Order != 0 {
+			unset = false
+		}
+	}
+	if unset {
+		for i := range units {
+			units[i].Order = i + 1
+		}
+		return nil
+	}
+
+	seen := make(map[int]bool, len(units))
+	for _, u := range units {
+		if u.Order <= 0 || seen[u.Order] {
+			return fmt.Errorf("%w: 单元顺序必须为不重复的正整数", ErrInvalidCourse)
+		}
+		seen[u.Order] = true
+	}
+	return nil
 }
 
-// IncrementEnrollment 增加报名人数（原子操作）
+// IncrementEnrollment 增加报名人数（原子操作），达到报名人数上限时返回 ErrCourseFull
 func (s *CourseService) IncrementEnrollment(courseID uint) error {
-	return s.db.Model(&Course{}).
+	result := s.db.Model(&Course{}).
 		Where("id = ?", courseID).
-		UpdateColumn("enrollment_count", gorm.Expr("enrollment_count + 1")).
-		Error
+		Where("max_enrollments = 0 OR enrollment_count < max_enrollments").
+		UpdateColumn("enrollment_count", gorm.Expr("enrollment_count + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCourseFull
+	}
+	return nil
 }
 
 // IncrementCompletion 增加完成人数（原子操作）
@@ -87,8 +260,86 @@ func (s *CourseService) IncrementCompletion(courseID uint) error {
 		Error
 }
 
-// 生成默认封面（示例实现）
-func generateDefaultCover(name string) string {
-	// 实际应实现生成纯色背景+文字的逻辑
-	return ""
+var (
+	ErrInvalidEnrollmentCode = errors.New("报名码无效")
+	ErrCourseClosed          = errors.New("课程未开放报名")
+	ErrEnrollmentNotStarted  = errors.New("报名尚未开始")
+	ErrEnrollmentEnded       = errors.New("报名已截止")
+	ErrCourseFull            = errors.New("报名人数已满")
+	ErrAlreadyEnrolled       = errors.New("已报名该课程")
+)
+
+// Enroll 学员凭报名码报名课程，在同一事务内创建报名记录并增加报名人数
+func (s *CourseService) Enroll(userID uint, code string) (*Enrollment, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return nil, ErrInvalidEnrollmentCode
+	}
+
+	var enrollment Enrollment
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var course Course
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("enrollment_code = ?", code).First(&course).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidEnrollmentCode
+			}
+			return err
+		}
+
+		now := time.Now()
+		switch {
+		case !course.IsOpen:
+			return ErrCourseClosed
+		case course.EnrollStartAt != nil && now.Before(*course.EnrollStartAt):
+			return ErrEnrollmentNotStarted
+		case course.EnrollEndAt != nil && now.After(*course.EnrollEndAt):
+			return ErrEnrollmentEnded
+		}
+
+		if _, err := FindEnrollment(tx, userID, course.ID); err == nil {
+			return ErrAlreadyEnrolled
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if err := NewCourseService(tx).IncrementEnrollment(course.ID); err != nil {
+			return err
+		}
+
+		enrollment = Enrollment{UserID: userID, CourseID: course.ID, EnrolledAt: now}
+		if err := tx.Create(&enrollment).Error; err != nil {
+			return err
+		}
+		enrollment.Course = course
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &enrollment, nil
+}
+
+// RegenerateCover 按课程当前名称重新生成默认封面并替换原封面，用于课程改名后更新封面文字
+func (s *CourseService) RegenerateCover(courseID uint) (*Course, error) {
+	if s.cover == nil {
+		return nil, ErrCoverUnavailable
+	}
+
+	var course Course
+	if err := s.db.First(&course, courseID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCourseNotFound
+		}
+		return nil, err
+	}
+
+	url, err := s.cover(course.Name)
+	if err != nil {
+		return nil, fmt.Errorf("生成默认封面失败: %w", err)
+	}
+	if err := s.db.Model(&course).Update("cover_image", url).Error; err != nil {
+		return nil, err
+	}
+	return &course, nil
 }
@@ -0,0 +1,192 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrCourseInUse = errors.New("课程考试仍有作答中或未判完的答卷，无法删除")
+
+// CourseDeletion 删除（或恢复）课程影响的记录数
+type CourseDeletion struct {
+	CourseID      uint  `json:"course_id"`
+	DryRun        bool  `json:"dry_run"`
+	Units         int64 `json:"units"`          // 课程单元
+	Videos        int64 `json:"videos"`         // 视频
+	Subtitles     int64 `json:"subtitles"`      // 视频字幕
+	Chapters      int64 `json:"chapters"`       // 视频章节
+	Enrollments   int64 `json:"enrollments"`    // 报名记录
+	VideoProgress int64 `json:"video_progress"` // 视频观看记录
+	Exams         int64 `json:"exams"`          // 课程考试
+	ExamSettings  int64 `json:"exam_settings"`  // 课程考试的试题配置与分数规则
+	Prerequisites int64 `json:"prerequisites"`  // 课程考试的前置条件，以及其他考试以本课程为前置的条件
+}
+
+// cascadeStep 随课程一并删除的一类记录
+type cascadeStep struct {
+	model any
+	where string
+	args  []any
+	count func(d *CourseDeletion) *int64
+}
+
+// courseCascade 列出课程删除时需要级联处理的记录，子记录在前
+func courseCascade(courseID uint) []cascadeStep {
+	courseExams := "SELECT id FROM exams WHERE belongs_type = ? AND belongs_id = ?"
+	courseVideos := "SELECT id FROM videos WHERE course_id = ?"
+	return []cascadeStep{
+		{
+			model: &UserVideoProgress{},
+			where: "enrollment_id IN (SELECT id FROM enrollments WHERE course_id = ?)",
+			args:  []any{courseID},
+			count: func(d *CourseDeletion) *int64 { return &d.VideoProgress },
+		},
+		{
+			model: &Enrollment{},
+			where: "course_id = ?",
+			args:  []any{courseID},
+			count: func(d *CourseDeletion) *int64 { return &d.Enrollments },
+		},
+		{
+			model: &VideoSubtitle{},
+			where: "video_id IN (" + courseVideos + ")",
+			args:  []any{courseID},
+			count: func(d *CourseDeletion) *int64 { return &d.Subtitles },
+		},
+		{
+			model: &VideoChapter{},
+			where: "video_id IN (" + courseVideos + ")",
+			args:  []any{courseID},
+			count: func(d *CourseDeletion) *int64 { return &d.Chapters },
+		},
+		{
+			model: &Video{},
+			where: "course_id = ?",
+			args:  []any{courseID},
+			count: func(d *CourseDeletion) *int64 { return &d.Videos },
+		},
+		{
+			model: &CourseUnit{},
+			where: "course_id = ?",
+			args:  []any{courseID},
+			count: func(d *CourseDeletion) *int64 { return &d.Units },
+		},
+		{
+			model: &ExamQuestionConfig{},
+			where: "exam_id IN (" + courseExams + ")",
+			args:  []any{ExamBelongsCourse, courseID},
+			count: func(d *CourseDeletion) *int64 { return &d.ExamSettings },
+		},
+		{
+			model: &ExamScoreRule{},
+			where: "exam_id IN (" + courseExams + ")",
+			args:  []any{ExamBelongsCourse, courseID},
+			count: func(d *CourseDeletion) *int64 { return &d.ExamSettings },
+		},
+		{
+			model: &ExamPrerequisite{},
+			where: "(exam_id IN (" + courseExams + ") OR course_id = ?)",
+			args:  []any{ExamBelongsCourse, courseID, courseID},
+			count: func(d *CourseDeletion) *int64 { return &d.Prerequisites },
+		},
+		{
+			model: &Exam{},
+			where: "belongs_type = ? AND belongs_id = ?",
+			args:  []any{ExamBelongsCourse, courseID},
+			count: func(d *CourseDeletion) *int64 { return &d.Exams },
+		},
+	}
+}
+
+// DeleteCourse 删除课程及其单元、视频、报名与观看记录、课程考试，所有记录使用同一删除时间，
+// 以便恢复时区分之前单独删除过的记录。dryRun 为 true 时只统计影响范围，不做修改。
+// 课程考试有进行中或尚未判完的作答时拒绝删除，避免待判分的答卷随考试一起被删除。
+func (s *CourseService) DeleteCourse(courseID uint, dryRun bool) (*CourseDeletion, error) {
+	report := &CourseDeletion{CourseID: courseID, DryRun: dryRun}
+	now := time.Now()
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var course Course
+		if err := tx.Select("id").First(&course, courseID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCourseNotFound
+			}
+			return err
+		}
+
+		var active int64
+		if err := tx.Model(&ExamAttempt{}).
+			Joins("JOIN exams ON exams.id = exam_attempts.exam_id").
+			Where("exams.belongs_type = ? AND exams.belongs_id = ? AND exams.deleted_at IS NULL", ExamBelongsCourse, courseID).
+			Where("exam_attempts.status IN ?", unfinishedAttemptStatuses).
+			Count(&active).Error; err != nil {
+			return err
+		}
+		if active > 0 {
+			return ErrCourseInUse
+		}
+
+		for _, step := range courseCascade(courseID) {
+			query := tx.Model(step.model).Where(step.where, step.args...)
+			var n int64
+			if dryRun {
+				if err := query.Count(&n).Error; err != nil {
+					return err
+				}
+			} else {
+				result := query.UpdateColumn("deleted_at", now)
+				if result.Error != nil {
+					return result.Error
+				}
+				n = result.RowsAffected
+			}
+			*step.count(report) += n
+		}
+
+		if dryRun {
+			return nil
+		}
+		return tx.Model(&course).UpdateColumn("deleted_at", now).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// RestoreCourse 恢复已删除的课程及随之删除的记录，返回恢复的记录数
+func (s *CourseService) RestoreCourse(courseID uint) (*CourseDeletion, error) {
+	report := &CourseDeletion{CourseID: courseID}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var course Course
+		if err := tx.Unscoped().Where("deleted_at IS NOT NULL").First(&course, courseID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCourseNotFound
+			}
+			return err
+		}
+		deletedAt := course.DeletedAt
+
+		steps := courseCascade(courseID)
+		for i := len(steps) - 1; i >= 0; i-- {
+			step := steps[i]
+			result := tx.Unscoped().Model(step.model).
+				Where(step.where, step.args...).
+				Where("deleted_at = ?", deletedAt).
+				UpdateColumn("deleted_at", nil)
+			if result.Error != nil {
+				return result.Error
+			}
+			*step.count(report) += result.RowsAffected
+		}
+
+		return tx.Unscoped().Model(&course).UpdateColumn("deleted_at", nil).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestDeleteCourseRefusesUngradedAttempts(t *testing.T) {
+	db := openTestDB(t)
+	course := Course{Name: "安全生产", EnrollmentCode: "c1"}
+	db.Create(&course)
+	now := time.Now()
+	exam := Exam{Name: "结业考试", StartTime: now, EndTime: now, Duration: 30, BelongsType: ExamBelongsCourse, BelongsID: course.ID}
+	// 跳过校验，无需准备试题配置
+	if err := db.Session(&gorm.Session{SkipHooks: true}).Create(&exam).Error; err != nil {
+		t.Fatal(err)
+	}
+	attempt := ExamAttempt{UserID: 1, ExamID: exam.ID, StartTime: now, Deadline: now}
+	db.Create(&attempt)
+
+	svc := NewCourseService(db)
+	for _, status := range []string{AttemptStatusInProgress, AttemptStatusSubmitted, AttemptStatusPending} {
+		db.Model(&attempt).Update("status", status)
+		if _, err := svc.DeleteCourse(course.ID, true); !errors.Is(err, ErrCourseInUse) {
+			t.Fatalf("status=%s: got %v，应拒绝删除", status, err)
+		}
+	}
+
+	db.Model(&attempt).Update("status", AttemptStatusGraded)
+	report, err := svc.DeleteCourse(course.ID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Exams != 1 {
+		t.Fatalf("删除的考试数 = %d", report.Exams)
+	}
+}
+
+func TestDeleteAndRestoreCourse(t *testing.T) {
+	db := openTestDB(t)
+	raw := db.Session(&gorm.Session{SkipHooks: true})
+	now := time.Now()
+	course := Course{Name: "安全生产", EnrollmentCode: "c1"}
+	other := Course{Name: "消防安全", EnrollmentCode: "c2"}
+	db.Create(&course)
+	db.Create(&other)
+
+	db.Create(&CourseUnit{CourseID: course.ID, UnitName: "第一单元", Order: 1})
+	oldUnit := CourseUnit{CourseID: course.ID, UnitName: "旧单元", Order: 2}
+	db.Create(&oldUnit)
+	v1 := Video{CourseID: course.ID, Title: "第一课", URL: "1.mp4", Duration: 600}
+	v2 := Video{CourseID: course.ID, Title: "第二课", URL: "2.mp4", Duration: 600}
+	db.Create(&v1)
+	db.Create(&v2)
+	db.Create(&VideoSubtitle{VideoID: v1.ID, Language: "zh-CN", URL: "1.vtt"})
+	db.Create(&VideoChapter{VideoID: v1.ID, Start: 0, Title: "开场"})
+	oldChapter := VideoChapter{VideoID: v2.ID, Start: 0, Title: "开场"}
+	db.Create(&oldChapter)
+	enrollment := Enrollment{UserID: 1, CourseID: course.ID}
+	db.Create(&enrollment)
+	db.Create(&UserVideoProgress{EnrollmentID: enrollment.ID, VideoID: v1.ID, Position: 100})
+
+	exam := Exam{Name: "结业考试", StartTime: now, EndTime: now, Duration: 30, BelongsType: ExamBelongsCourse, BelongsID: course.ID}
+	otherExam := Exam{Name: "消防考试", StartTime: now, EndTime: now, Duration: 30, BelongsType: ExamBelongsCourse, BelongsID: other.ID}
+	raw.Create(&exam)
+	raw.Create(&otherExam)
+	db.Create(&ExamQuestionConfig{ExamID: exam.ID, QuestionBankID: 1, Amount: 1})
+	db.Create(&ExamScoreRule{ExamID: exam.ID, RuleType: ScoreRuleType, TargetType: QuestionTypeSubjective, ScorePerQuestion: 20})
+	oldRule := ExamScoreRule{ExamID: exam.ID, RuleType: ScoreRuleType, TargetType: QuestionTypeSingleChoice, ScorePerQuestion: 5}
+	db.Create(&oldRule)
+	db.Create(&ExamPrerequisite{ExamID: otherExam.ID, CourseID: course.ID})
+
+	// 课程删除前已单独删除的记录，恢复课程时不应一并恢复
+	db.Delete(&oldUnit)
+	db.Delete(&v2)
+	db.Delete(&oldChapter)
+	db.Delete(&oldRule)
+
+	want := &CourseDeletion{
+		CourseID: course.ID, Units: 1, Videos: 1, Subtitles: 1, Chapters: 1, Enrollments: 1,
+		VideoProgress: 1, Exams: 1, ExamSettings: 2, Prerequisites: 1,
+	}
+
+	svc := NewCourseService(db)
+	dry, err := svc.DeleteCourse(course.ID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.First(&Course{}, course.ID).Error; err != nil {
+		t.Fatalf("dry run 不应删除课程: %v", err)
+	}
+	deleted, err := svc.DeleteCourse(course.ID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dry.DryRun = false
+	if !reflect.DeepEqual(dry, deleted) || !reflect.DeepEqual(deleted, want) {
+		t.Fatalf("dry run %+v\n删除 %+v\n期望 %+v", dry, deleted, want)
+	}
+	if err := db.First(&Course{}, course.ID).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("课程未删除: %v", err)
+	}
+	var remaining int64
+	db.Model(&Video{}).Where("course_id = ?", course.ID).Count(&remaining)
+	if remaining != 0 {
+		t.Fatalf("删除后仍有 %d 个视频", remaining)
+	}
+
+	restored, err := svc.RestoreCourse(course.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(restored, want) {
+		t.Fatalf("恢复 %+v\n期望 %+v", restored, want)
+	}
+	if err := db.First(&Course{}, course.ID).Error; err != nil {
+		t.Fatalf("课程未恢复: %v", err)
+	}
+	for name, model := range map[string]any{"单元": &oldUnit, "视频": &v2, "章节": &oldChapter, "分数规则": &oldRule} {
+		if err := db.First(model).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("之前删除的%s被一并恢复: %v", name, err)
+		}
+	}
+	if err := db.First(&Video{}, v1.ID).Error; err != nil {
+		t.Fatalf("视频未恢复: %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrCourseNotFound   = errors.New("课程不存在")
+	ErrUnitNotFound     = errors.New("单元不存在")
+	ErrInvalidUnitOrder = errors.New("单元排序无效")
+	ErrVideoNotInCourse = errors.New("视频不属于该课程")
+)
+
+// unitOrderColumn order 为 SQL 关键字，排序与更新时需要转义
+var unitOrderColumn = clause.Column{Name: "order"}
+
+// OrderUnits 按单元顺序排序
+func OrderUnits(db *gorm.DB) *gorm.DB {
+	return db.Order(clause.OrderByColumn{Column: unitOrderColumn})
+}
+
+// ListUnits 返回课程的全部单元，按顺序排列
+func (s *CourseService) ListUnits(courseID uint) ([]CourseUnit, error) {
+	if !recordExists(s.db, &Course{}, courseID) {
+		return nil, ErrCourseNotFound
+	}
+	var units []CourseUnit
+	err := OrderUnits(s.db.Where("course_id = ?", courseID)).Find(&units).Error
+	return units, err
+}
+
+// AddUnit 在课程末尾添加单元
+func (s *CourseService) AddUnit(courseID uint, unit *CourseUnit) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var course Course
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&course, courseID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCourseNotFound
+			}
+			return err
+		}
+
+		var last int
+		if err := tx.Model(&CourseUnit{}).
+			Where("course_id = ?", courseID).
+			Select("COALESCE(MAX(" + tx.Statement.Quote(unitOrderColumn) + "), 0)").
+			Scan(&last).Error; err != nil {
+			return err
+		}
+
+		unit.ID = 0
+		unit.CourseID = courseID
+		unit.Order = last + 1
+		return tx.Create(unit).Error
+	})
+}
+
+// UpdateUnit 修改单元名称与描述
+func (s *CourseService) UpdateUnit(unitID uint, updates map[string]any) (*CourseUnit, error) {
+	var unit CourseUnit
+	if err := s.db.First(&unit, unitID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUnitNotFound
+		}
+		return nil, err
+	}
+	if len(updates) == 0 {
+		return &unit, nil
+	}
+	if err := s.db.Model(&unit).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	return &unit, nil
+}
+
+// DeleteUnit 删除单元。单元内的视频移入 moveTo 单元，moveTo 为空时视频不再属于任何单元；
+// 其余单元的顺序随之前移。
+func (s *CourseService) DeleteUnit(unitID uint, moveTo *uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var unit CourseUnit
+		if err := tx.First(&unit, unitID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUnitNotFound
+			}
+			return err
+		}
+
+		if moveTo != nil {
+			if *moveTo == unit.ID {
+				return fmt.Errorf("%w: 不能移入待删除的单元", ErrInvalidUnitOrder)
+			}
+			if err := checkUnitInCourse(tx, *moveTo, unit.CourseID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&Video{}).Where("unit_id = ?", unit.ID).Update("unit_id", moveTo).Error; err != nil {
+			return err
+		}
+
+		// 已删除的单元仍占用唯一索引，先把顺序改为负的ID以释放原位置
+		if err := tx.Model(&unit).UpdateColumn(unitOrderColumn.Name, -int(unit.ID)).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&unit).Error; err != nil {
+			return err
+		}
+
+		var remaining []CourseUnit
+		if err := OrderUnits(tx.Where("course_id = ?", unit.CourseID)).Find(&remaining).Error; err != nil {
+			return err
+		}
+		ids := make([]uint, len(remaining))
+		for i, u := range remaining {
+			ids[i] = u.ID
+		}
+		return applyUnitOrder(tx, unit.CourseID, ids)
+	})
+}
+
+// ReorderUnits 按给定顺序重排课程单元，unitIDs 必须恰好包含课程的全部单元
+func (s *CourseService) ReorderUnits(courseID uint, unitIDs []uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var course Course
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&course, courseID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCourseNotFound
+			}
+			return err
+		}
+
+		var existing []uint
+		if err := tx.Model(&CourseUnit{}).Where("course_id = ?", courseID).Pluck("id", &existing).Error; err != nil {
+			return err
+		}
+		if len(existing) != len(unitIDs) {
+			return fmt.Errorf("%w: 需要包含课程的全部 %d 个单元", ErrInvalidUnitOrder, len(existing))
+		}
+		want := make(map[uint]bool, len(existing))
+		for _, id := range existing {
+			want[id] = true
+		}
+		for _, id := range unitIDs {
+			if !want[id] {
+				return fmt.Errorf("%w: 单元 %d 不属于该课程或重复出现", ErrInvalidUnitOrder, id)
+			}
+			delete(want, id)
+		}
+
+		return applyUnitOrder(tx, courseID, unitIDs)
+	})
+}
+
+// applyUnitOrder 将单元顺序依次设为 1..n。先把全部单元移到负数位置，避免交换顺序时触发唯一索引冲突
+func applyUnitOrder(tx *gorm.DB, courseID uint, unitIDs []uint) error {
+	if len(unitIDs) == 0 {
+		return nil
+	}
+	if err := tx.Model(&CourseUnit{}).
+		Where("course_id = ? AND id IN ?", courseID, unitIDs).
+		UpdateColumn(unitOrderColumn.Name, gorm.Expr("-id")).Error; err != nil {
+		return err
+	}
+	for i, id := range unitIDs {
+		if err := tx.Model(&CourseUnit{}).
+			Where("id = ?", id).
+			UpdateColumn(unitOrderColumn.Name, i+1).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MoveVideos 将课程中的视频批量移入单元，unitID 为空时视频不再属于任何单元
+func (s *CourseService) MoveVideos(courseID uint, videoIDs []uint, unitID *uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if !recordExists(tx, &Course{}, courseID) {
+			return ErrCourseNotFound
+		}
+		if unitID != nil {
+			if err := checkUnitInCourse(tx, *unitID, courseID); err != nil {
+				return err
+			}
+		}
+
+		var count int64
+		if err := tx.Model(&Video{}).
+			Where("course_id = ? AND id IN ?", courseID, videoIDs).
+			Distinct("id").
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if int(count) != len(uniqueIDs(videoIDs)) {
+			return ErrVideoNotInCourse
+		}
+
+		return tx.Model(&Video{}).
+			Where("course_id = ? AND id IN ?", courseID, videoIDs).
+			Update("unit_id", unitID).Error
+	})
+}
+
+func checkUnitInCourse(tx *gorm.DB, unitID, courseID uint) error {
+	var unit CourseUnit
+	if err := tx.Select("id", "course_id").First(&unit, unitID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUnitNotFound
+		}
+		return err
+	}
+	if unit.CourseID != courseID {
+		return fmt.Errorf("%w: 单元 %d 不属于该课程", ErrUnitNotFound, unitID)
+	}
+	return nil
+}
+
+func uniqueIDs(ids []uint) map[uint]bool {
+	set := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
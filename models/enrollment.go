@@ -6,36 +6,85 @@ import (
 	"gorm.io/gorm"
 )
 
-// 课程报名信息主表
+// 课程报名信息主表，是用户参与课程、学习进度与完成情况的唯一记录
 type Enrollment struct {
 	gorm.Model
 	UserID      uint       `gorm:"not null;index"`            // 用户ID
 	CourseID    uint       `gorm:"not null;index"`            // 课程ID
 	EnrolledAt  time.Time  `gorm:"default:CURRENT_TIMESTAMP"` // 报名时间
+	Progress    uint       `gorm:"default:0"`                 // 学习进度百分比（0-100）
 	IsCompleted bool       `gorm:"default:false"`             // 是否完成课程
 	CompletedAt *time.Time // 完成时间
 
 	// 关联关系
-	User          User                `gorm:"foreignKey:UserID"`
+	User          User                `gorm:"foreignKey:UserID" json:"-"`
 	Course        Course              `gorm:"foreignKey:CourseID"`
 	VideoProgress []UserVideoProgress // 视频观看记录
 	ExamRecords   []ExamRecord        // 考试记录
 }
 
+// FindEnrollment 查找用户在课程中的报名记录，未报名时返回 gorm.ErrRecordNotFound
+func FindEnrollment(db *gorm.DB, userID, courseID uint) (*Enrollment, error) {
+	var enrollment Enrollment
+	err := db.Where("user_id = ? AND course_id = ?", userID, courseID).
+		Order("id ASC").First(&enrollment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &enrollment, nil
+}
+
+// DeleteUserEnrollments 删除用户的全部报名记录，并相应减少课程的报名与完成人数
+func DeleteUserEnrollments(tx *gorm.DB, userID uint) error {
+	var enrollments []Enrollment
+	if err := tx.Where("user_id = ?", userID).Find(&enrollments).Error; err != nil {
+		return err
+	}
+	for _, e := range enrollments {
+		if err := releaseEnrollment(tx, &e); err != nil {
+			return err
+		}
+	}
+	return tx.Where("user_id = ?", userID).Delete(&Enrollment{}).Error
+}
+
+// releaseEnrollment 报名记录删除前减少课程的报名与完成人数
+func releaseEnrollment(tx *gorm.DB, e *Enrollment) error {
+	updates := map[string]any{
+		"enrollment_count": gorm.Expr("CASE WHEN enrollment_count > 0 THEN enrollment_count - 1 ELSE 0 END"),
+	}
+	if e.IsCompleted {
+		updates["completion_count"] = gorm.Expr("CASE WHEN completion_count > 0 THEN completion_count - 1 ELSE 0 END")
+	}
+	return tx.Model(&Course{}).Where("id = ?", e.CourseID).UpdateColumns(updates).Error
+}
+
 // 视频观看进度表
 type UserVideoProgress struct {
 	gorm.Model
-	EnrollmentID uint      `gorm:"not null;index"` // 报名记录ID
-	VideoID      uint      `gorm:"not null;index"` // 视频ID
-	LastWatched  time.Time // 最后观看时间
-	Progress     float64   `gorm:"default:0"`     // 观看进度百分比
-	IsCompleted  bool      `gorm:"default:false"` // 是否看完
+	EnrollmentID    uint      `gorm:"not null;index:idx_enrollment_video,unique"` // 报名记录ID
+	VideoID         uint      `gorm:"not null;index:idx_enrollment_video,unique"` // 视频ID
+	LastWatched     time.Time // 最后观看时间（最近一次心跳）
+	Progress        float64   `gorm:"default:0"`        // 观看进度百分比
+	IsCompleted     bool      `gorm:"default:false"`    // 是否看完
+	Position        float64   `gorm:"default:0"`        // 已有效观看到的最远位置（秒）
+	SessionID       string    `gorm:"type:varchar(64)"` // 当前播放会话
+	SessionPosition float64   `gorm:"default:0"`        // 当前会话最近一次上报的位置（秒）
 
 	// 关联关系
 	Enrollment Enrollment `gorm:"foreignKey:EnrollmentID"`
 	Video      Video      `gorm:"foreignKey:VideoID"`
 }
 
+// 观看会话记录，每个播放会话只计一次视频观看次数，轮换会话ID不会重复计数
+type VideoWatchSession struct {
+	ID           uint      `gorm:"primarykey"`
+	EnrollmentID uint      `gorm:"not null;index:idx_enrollment_video_session,unique"`
+	VideoID      uint      `gorm:"not null;index:idx_enrollment_video_session,unique"`
+	SessionID    string    `gorm:"type:varchar(64);not null;index:idx_enrollment_video_session,unique"`
+	CreatedAt    time.Time // 会话首个心跳时间
+}
+
 // 考试记录表
 type ExamRecord struct {
 	gorm.Model
@@ -46,4 +95,3 @@ type ExamRecord struct {
 
 	Enrollment Enrollment `gorm:"foreignKey:EnrollmentID"`
 }
-
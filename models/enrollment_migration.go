@@ -0,0 +1,137 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 旧版用户-课程关联表，已由 Enrollment 取代，仅用于数据迁移
+const legacyUserCourseTable = "user_courses"
+
+// 迁移完成后旧表改名保留，便于核对
+const migratedUserCourseTable = "user_courses_migrated"
+
+type legacyUserCourse struct {
+	UserID      uint
+	CourseID    uint
+	EnrolledAt  *time.Time
+	IsCompleted bool
+	CompletedAt *time.Time
+	Progress    uint
+}
+
+// MigrateUserCourses 将旧版 user_courses 表中的记录合并到报名记录：
+// 没有报名记录的补建，已有的取两者中较早的报名与完成时间、较高的进度。
+// 之后按报名记录重新评估学习进度，并重新统计各课程的报名与完成人数。
+// 旧表迁移后改名为 user_courses_migrated，重复执行不会重复合并。
+func MigrateUserCourses(db *gorm.DB) error {
+	if !db.Migrator().HasTable(legacyUserCourseTable) {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		// 早期的多对多关联表只有 user_id 与 course_id 两列
+		columnTypes, err := tx.Migrator().ColumnTypes(legacyUserCourseTable)
+		if err != nil {
+			return err
+		}
+		existing := make(map[string]bool, len(columnTypes))
+		for _, ct := range columnTypes {
+			existing[ct.Name()] = true
+		}
+		columns := []string{"user_id", "course_id"}
+		for _, col := range []string{"enrolled_at", "is_completed", "completed_at", "progress"} {
+			if existing[col] {
+				columns = append(columns, col)
+			}
+		}
+
+		var rows []legacyUserCourse
+		if err := tx.Table(legacyUserCourseTable).Select(columns).Find(&rows).Error; err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			if err := mergeLegacyUserCourse(tx, row); err != nil {
+				return err
+			}
+		}
+
+		var enrollments []Enrollment
+		if err := tx.Select("user_id", "course_id").Find(&enrollments).Error; err != nil {
+			return err
+		}
+		for _, e := range enrollments {
+			if _, err := NewCourseService(tx).EvaluateCompletion(e.UserID, e.CourseID); err != nil {
+				return err
+			}
+		}
+
+		if err := RecountCourseEnrollments(tx); err != nil {
+			return err
+		}
+		return tx.Migrator().RenameTable(legacyUserCourseTable, migratedUserCourseTable)
+	})
+}
+
+func mergeLegacyUserCourse(tx *gorm.DB, row legacyUserCourse) error {
+	if row.Progress > 100 {
+		row.Progress = 100
+	}
+	if row.IsCompleted {
+		row.Progress = 100
+		if row.CompletedAt == nil {
+			now := time.Now()
+			row.CompletedAt = &now
+		}
+	}
+
+	var enrollment Enrollment
+	err := tx.Where("user_id = ? AND course_id = ?", row.UserID, row.CourseID).
+		Order("id ASC").First(&enrollment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		enrollment = Enrollment{
+			UserID:      row.UserID,
+			CourseID:    row.CourseID,
+			EnrolledAt:  time.Now(),
+			Progress:    row.Progress,
+			IsCompleted: row.IsCompleted,
+			CompletedAt: row.CompletedAt,
+		}
+		if row.EnrolledAt != nil {
+			enrollment.EnrolledAt = *row.EnrolledAt
+		}
+		return tx.Create(&enrollment).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	updates := map[string]any{}
+	if row.EnrolledAt != nil && row.EnrolledAt.Before(enrollment.EnrolledAt) {
+		updates["enrolled_at"] = *row.EnrolledAt
+	}
+	if row.Progress > enrollment.Progress {
+		updates["progress"] = row.Progress
+	}
+	if row.IsCompleted {
+		updates["is_completed"] = true
+		if enrollment.CompletedAt == nil || row.CompletedAt.Before(*enrollment.CompletedAt) {
+			updates["completed_at"] = *row.CompletedAt
+		}
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return tx.Model(&enrollment).Updates(updates).Error
+}
+
+// RecountCourseEnrollments 按报名记录重新统计全部课程的报名人数与完成人数
+func RecountCourseEnrollments(tx *gorm.DB) error {
+	return tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Model(&Course{}).UpdateColumns(map[string]any{
+		"enrollment_count": gorm.Expr("(SELECT COUNT(*) FROM enrollments WHERE enrollments.course_id = courses.id AND enrollments.deleted_at IS NULL)"),
+		"completion_count": gorm.Expr("(SELECT COUNT(*) FROM enrollments WHERE enrollments.course_id = courses.id AND enrollments.deleted_at IS NULL AND enrollments.is_completed = ?)", true),
+	}).Error
+}
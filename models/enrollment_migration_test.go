@@ -0,0 +1,106 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMigrateUserCourses(t *testing.T) {
+	db := openTestDB(t)
+	course := Course{Name: "安全生产", EnrollmentCode: "c1"}
+	other := Course{Name: "消防安全", EnrollmentCode: "c2"}
+	db.Create(&course)
+	db.Create(&other)
+
+	enrolledAt := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	completedAt := time.Date(2024, 3, 10, 8, 0, 0, 0, time.UTC)
+	existing := Enrollment{UserID: 1, CourseID: course.ID, EnrolledAt: enrolledAt.AddDate(0, 1, 0), Progress: 30}
+	db.Create(&existing)
+
+	if err := db.Exec(`CREATE TABLE user_courses (user_id integer, course_id integer, enrolled_at datetime,
+		is_completed numeric, completed_at datetime, progress integer)`).Error; err != nil {
+		t.Fatal(err)
+	}
+	insert := "INSERT INTO user_courses VALUES (?, ?, ?, ?, ?, ?)"
+	// 已有报名记录：取较早的报名时间，旧表中已完成
+	db.Exec(insert, 1, course.ID, enrolledAt, true, completedAt, 80)
+	// 没有报名记录：补建
+	db.Exec(insert, 2, course.ID, nil, false, nil, 40)
+	db.Exec(insert, 2, other.ID, enrolledAt, false, nil, 150)
+
+	if err := MigrateUserCourses(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var merged Enrollment
+	db.First(&merged, existing.ID)
+	if !merged.IsCompleted || merged.Progress != 100 || merged.CompletedAt == nil || !merged.CompletedAt.Equal(completedAt) {
+		t.Fatalf("合并后 progress=%d completed=%v completed_at=%v", merged.Progress, merged.IsCompleted, merged.CompletedAt)
+	}
+	if !merged.EnrolledAt.Equal(enrolledAt) {
+		t.Fatalf("报名时间应取较早者: %v", merged.EnrolledAt)
+	}
+
+	var created []Enrollment
+	db.Where("user_id = ?", 2).Order("course_id").Find(&created)
+	if len(created) != 2 || created[0].Progress != 40 || created[0].IsCompleted || created[1].Progress != 100 {
+		t.Fatalf("补建的报名记录: %+v", created)
+	}
+
+	db.First(&course, course.ID)
+	db.First(&other, other.ID)
+	if course.EnrollmentCount != 2 || course.CompletionCount != 1 || other.EnrollmentCount != 1 || other.CompletionCount != 0 {
+		t.Fatalf("课程人数: %d/%d %d/%d", course.EnrollmentCount, course.CompletionCount,
+			other.EnrollmentCount, other.CompletionCount)
+	}
+
+	if db.Migrator().HasTable(legacyUserCourseTable) || !db.Migrator().HasTable(migratedUserCourseTable) {
+		t.Fatal("旧表未改名")
+	}
+
+	// 再次执行不应重复合并
+	db.Model(&Course{}).Where("id = ?", course.ID).UpdateColumn("enrollment_count", 99)
+	if err := MigrateUserCourses(db); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	db.Model(&Enrollment{}).Count(&count)
+	db.First(&course, course.ID)
+	if count != 3 || course.EnrollmentCount != 99 {
+		t.Fatalf("重复执行后 enrollments=%d enrollment_count=%d", count, course.EnrollmentCount)
+	}
+}
+
+func TestMigrateUserCoursesJoinTable(t *testing.T) {
+	db := openTestDB(t)
+	course := Course{Name: "安全生产", EnrollmentCode: "c1"}
+	db.Create(&course)
+
+	// 早期的多对多关联表只有两列
+	if err := db.Exec("CREATE TABLE user_courses (user_id integer, course_id integer)").Error; err != nil {
+		t.Fatal(err)
+	}
+	db.Exec("INSERT INTO user_courses VALUES (?, ?), (?, ?), (?, ?)", 1, course.ID, 1, course.ID, 2, course.ID)
+
+	if err := MigrateUserCourses(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var enrollments []Enrollment
+	db.Order("user_id").Find(&enrollments)
+	if len(enrollments) != 2 || enrollments[0].UserID != 1 || enrollments[1].UserID != 2 {
+		t.Fatalf("报名记录: %+v", enrollments)
+	}
+	for _, e := range enrollments {
+		if e.Progress != 0 || e.IsCompleted || e.CompletedAt != nil || e.EnrolledAt.IsZero() {
+			t.Fatalf("只有关联关系的记录应按未开始学习补建: %+v", e)
+		}
+	}
+	db.First(&course, course.ID)
+	if course.EnrollmentCount != 2 || course.CompletionCount != 0 {
+		t.Fatalf("课程人数: %d/%d", course.EnrollmentCount, course.CompletionCount)
+	}
+	if !db.Migrator().HasTable(migratedUserCourseTable) {
+		t.Fatal("旧表未改名")
+	}
+}
@@ -2,6 +2,8 @@ package models
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -20,10 +22,15 @@ type Exam struct {
 	BelongsType  string    `gorm:"type:varchar(20);not null"`              // 所属类型 course/training
 	BelongsID    uint      `gorm:"not null"`                               // 所属实体ID
 
+	// 判分配置
+	MultipleChoiceScoring string `gorm:"type:varchar(20);default:'all_or_nothing'"`   // 多选题计分：all_or_nothing/proportional
+	BlankNormalize        string `gorm:"type:varchar(50);default:'case,space,width'"` // 填空题归一化：case/space/width 组合，none 表示严格匹配
+	ReviewPolicy          string `gorm:"type:varchar(20);default:'after_close'"`      // 交卷后查看答案与解析的时机：never/after_submit/after_close
+
 	// 关联配置
 	QuestionConfigs []ExamQuestionConfig `gorm:"foreignKey:ExamID"` // 试题配置
 	Prerequisites   []ExamPrerequisite   `gorm:"foreignKey:ExamID"` // 前置课程
-	ScoreRules      ExamScoreRule        `gorm:"foreignKey:ExamID"` // 分数规则
+	ScoreRules      []ExamScoreRule      `gorm:"foreignKey:ExamID"` // 分数规则
 }
 
 // 试题配置表
@@ -38,15 +45,51 @@ type ExamQuestionConfig struct {
 	Bank QuestionBank `gorm:"foreignKey:QuestionBankID"`
 }
 
-// 分数规则表
+// 分数规则类型
+const (
+	ScoreRuleBank = "bank" // 按题库
+	ScoreRuleType = "type" // 按题型
+)
+
+// 考试所属类型
+const (
+	ExamBelongsCourse   = "course"   // 课程考试
+	ExamBelongsTraining = "training" // 培训考试
+)
+
+// 多选题计分方式
+const (
+	ScoringAllOrNothing = "all_or_nothing" // 全对才得分
+	ScoringProportional = "proportional"   // 按选对比例得分，选错不得分
+)
+
+// 答案解析公开策略
+const (
+	ReviewNever       = "never"        // 不公开
+	ReviewAfterSubmit = "after_submit" // 交卷后公开
+	ReviewAfterClose  = "after_close"  // 考试结束后公开
+)
+
+// 分数规则表；同一题目同时命中时题库规则优先于题型规则，均未命中时使用题目自身分值
 type ExamScoreRule struct {
 	gorm.Model
-	ExamID           uint    `gorm:"not null;uniqueIndex"`
+	ExamID           uint    `gorm:"not null;index"`
 	RuleType         string  `gorm:"type:varchar(20);not null"` // 规则类型：bank/type
-	TargetID         uint    // 题库ID或题型标识
+	TargetID         uint    // 题库ID（bank 规则）
+	TargetType       string  `gorm:"type:varchar(20)"` // 题型标识（type 规则）
 	ScorePerQuestion float64 `gorm:"not null;check:score_per_question >= 0"`
 }
 
+// ScoreRulesAt 返回开考时刻生效的分数规则；考试修改或删除后，历史答题记录仍按当时的规则判分
+func ScoreRulesAt(db *gorm.DB, examID uint, asOf time.Time) ([]ExamScoreRule, error) {
+	var rules []ExamScoreRule
+	err := db.Unscoped().
+		Where("exam_id = ? AND created_at <= ?", examID, asOf).
+		Where("deleted_at IS NULL OR deleted_at > ?", asOf).
+		Find(&rules).Error
+	return rules, err
+}
+
 // 考试前置条件
 type ExamPrerequisite struct {
 	gorm.Model
@@ -63,30 +106,179 @@ func (e *Exam) Validate() error {
 		return errors.New("考试开始时间不能晚于结束时间")
 	}
 
+	if e.Duration <= 0 {
+		return errors.New("考试时长必须大于 0")
+	}
+
+	if e.MaxAttempts <= 0 {
+		return errors.New("最大尝试次数必须大于 0")
+	}
+
+	if e.PassingScore < 0 {
+		return errors.New("及格分数不能为负数")
+	}
+
 	if len(e.QuestionConfigs) == 0 {
 		return errors.New("必须配置试题")
 	}
 
-	if e.BelongsType != "course" && e.BelongsType != "training" {
+	if e.BelongsType != ExamBelongsCourse && e.BelongsType != ExamBelongsTraining {
 		return errors.New("无效的所属类型")
 	}
 
+	if e.MultipleChoiceScoring != "" && e.MultipleChoiceScoring != ScoringAllOrNothing &&
+		e.MultipleChoiceScoring != ScoringProportional {
+		return errors.New("无效的多选题计分方式")
+	}
+
+	if e.BlankNormalize != "" && e.BlankNormalize != "none" {
+		for _, flag := range strings.Split(e.BlankNormalize, ",") {
+			switch strings.TrimSpace(flag) {
+			case "case", "space", "width":
+			default:
+				return fmt.Errorf("无效的填空题归一化选项: %s", flag)
+			}
+		}
+	}
+
+	switch e.ReviewPolicy {
+	case "", ReviewNever, ReviewAfterSubmit, ReviewAfterClose:
+	default:
+		return errors.New("无效的答案公开策略")
+	}
+
+	for i, cfg := range e.QuestionConfigs {
+		if cfg.Amount <= 0 {
+			return fmt.Errorf("第 %d 条试题配置的抽题数量必须大于 0", i+1)
+		}
+		if cfg.QuestionType != "" && !IsValidQuestionType(cfg.QuestionType) {
+			return fmt.Errorf("第 %d 条试题配置的题型无效", i+1)
+		}
+		if cfg.Difficulty != nil && (*cfg.Difficulty < 1 || *cfg.Difficulty > 5) {
+			return fmt.Errorf("第 %d 条试题配置的难度必须在 1 到 5 之间", i+1)
+		}
+	}
+
+	courses := make(map[uint]bool)
+	for _, p := range e.Prerequisites {
+		if p.MinProgress < 0 || p.MinProgress > 100 {
+			return errors.New("前置课程进度要求必须在 0 到 100 之间")
+		}
+		if courses[p.CourseID] {
+			return fmt.Errorf("前置课程重复: %d", p.CourseID)
+		}
+		courses[p.CourseID] = true
+	}
+
+	rules := make(map[string]bool)
+	for _, r := range e.ScoreRules {
+		var key string
+		switch r.RuleType {
+		case ScoreRuleBank:
+			key = fmt.Sprintf("bank:%d", r.TargetID)
+		case ScoreRuleType:
+			if !IsValidQuestionType(r.TargetType) {
+				return fmt.Errorf("分数规则的题型无效: %s", r.TargetType)
+			}
+			key = "type:" + r.TargetType
+		default:
+			return fmt.Errorf("无效的分数规则类型: %s", r.RuleType)
+		}
+		if r.ScorePerQuestion < 0 {
+			return errors.New("每题分值不能为负数")
+		}
+		if rules[key] {
+			return fmt.Errorf("分数规则重复: %s", key)
+		}
+		rules[key] = true
+	}
+
+	return nil
+}
+
+// ValidateReferences 校验考试引用的题库、课程及所属实体是否存在，且题库中有足够的题目可抽
+func (e *Exam) ValidateReferences(db *gorm.DB) error {
+	switch e.BelongsType {
+	case ExamBelongsCourse:
+		if !recordExists(db, &Course{}, e.BelongsID) {
+			return fmt.Errorf("所属课程不存在: %d", e.BelongsID)
+		}
+	case ExamBelongsTraining:
+		// 培训尚无对应的数据表，无法校验所属实体
+		return errors.New("暂不支持所属类型 training")
+	}
+
+	for i, cfg := range e.QuestionConfigs {
+		if !recordExists(db, &QuestionBank{}, cfg.QuestionBankID) {
+			return fmt.Errorf("第 %d 条试题配置的题库不存在: %d", i+1, cfg.QuestionBankID)
+		}
+		query := db.Model(&Question{}).Where("bank_id = ?", cfg.QuestionBankID)
+		if cfg.QuestionType != "" {
+			query = query.Where("type = ?", cfg.QuestionType)
+		}
+		if cfg.Difficulty != nil {
+			query = query.Where("difficulty = ?", *cfg.Difficulty)
+		}
+		var available int64
+		if err := query.Count(&available).Error; err != nil {
+			return err
+		}
+		if int(available) < cfg.Amount {
+			return fmt.Errorf("%w: 第 %d 条试题配置需要 %d 道，题库 %d 中仅有 %d 道",
+				ErrInsufficientQuestions, i+1, cfg.Amount, cfg.QuestionBankID, available)
+		}
+	}
+
+	for _, p := range e.Prerequisites {
+		if !recordExists(db, &Course{}, p.CourseID) {
+			return fmt.Errorf("前置课程不存在: %d", p.CourseID)
+		}
+	}
+
+	for _, r := range e.ScoreRules {
+		if r.RuleType == ScoreRuleBank && !recordExists(db, &QuestionBank{}, r.TargetID) {
+			return fmt.Errorf("分数规则引用的题库不存在: %d", r.TargetID)
+		}
+	}
 	return nil
 }
 
+func recordExists(db *gorm.DB, model any, id uint) bool {
+	var count int64
+	db.Model(model).Where("id = ?", id).Count(&count)
+	return count > 0
+}
+
 // GORM钩子
 func (e *Exam) BeforeCreate(tx *gorm.DB) error {
 	return e.Validate()
 }
 
+// 答题记录状态
+const (
+	AttemptStatusInProgress = "in_progress"    // 作答中
+	AttemptStatusSubmitted  = "submitted"      // 已交卷
+	AttemptStatusPending    = "pending_review" // 待人工判分
+	AttemptStatusGraded     = "graded"         // 已判分
+)
+
+// unfinishedAttemptStatuses 尚未确定成绩的答题记录状态，所属考试不能删除
+var unfinishedAttemptStatuses = []string{AttemptStatusInProgress, AttemptStatusSubmitted, AttemptStatusPending}
+
 type ExamAttempt struct {
 	gorm.Model
-	UserID    uint `gorm:"not null;index"`
-	ExamID    uint `gorm:"not null;index"`
-	StartTime time.Time
-	EndTime   time.Time
-	Score     float64
-	IsPassed  bool
+	UserID        uint `gorm:"not null;index"`
+	ExamID        uint `gorm:"not null;index"`
+	StartTime     time.Time
+	EndTime       time.Time // 交卷时间
+	Deadline      time.Time `gorm:"not null"` // 作答截止时间（开考时长与考试窗口取较早者）
+	Status        string    `gorm:"type:varchar(20);not null;default:'in_progress';index"`
+	AutoSubmitted bool      `gorm:"default:false"` // 是否超时自动交卷
+	Seed          int64     // 抽题种子，与 StartTime 一起可重新生成同一份试卷
+	Paper         Paper     `gorm:"type:json"` // 抽题结果
+	Score         float64
+	IsPassed      bool
+	GradedAt      *time.Time // 判分完成时间
 
 	// 关联关系
 	User    User         `gorm:"foreignKey:UserID"`
@@ -94,12 +286,34 @@ type ExamAttempt struct {
 	Answers []ExamAnswer `gorm:"foreignKey:AttemptID"`
 }
 
+// ReviewAvailableAt 返回考生可以查看答案解析的时间，nil 表示不公开
+func (e *Exam) ReviewAvailableAt(attempt *ExamAttempt) *time.Time {
+	switch e.ReviewPolicy {
+	case ReviewAfterSubmit:
+		return &attempt.EndTime
+	case ReviewAfterClose:
+		return &e.EndTime
+	}
+	return nil
+}
+
+// IsExpired 判断作答时间是否已用完
+func (a *ExamAttempt) IsExpired(now time.Time) bool {
+	return !now.Before(a.Deadline)
+}
+
 type ExamAnswer struct {
 	gorm.Model
-	AttemptID  uint   `gorm:"not null;index"`
-	QuestionID uint   `gorm:"not null;index"`
-	UserAnswer string `gorm:"type:text"`
+	AttemptID  uint   `gorm:"not null;index:idx_attempt_question,unique"`
+	QuestionID uint   `gorm:"not null;index:idx_attempt_question,unique"`
+	UserAnswer string `gorm:"type:text"` // JSON 编码的作答内容
 	IsCorrect  bool
+	Score      float64 // 本题得分
+
+	// 主观题人工判分
+	ReviewerID *uint
+	ReviewedAt *time.Time
+	Comment    string `gorm:"type:text"`
 
 	Question Question `gorm:"foreignKey:QuestionID"`
 }
@@ -0,0 +1,478 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrExamNotFound       = errors.New("考试不存在")
+	ErrExamNotStarted     = errors.New("考试尚未开始")
+	ErrExamClosed         = errors.New("考试已结束")
+	ErrMaxAttemptsReached = errors.New("已达到最大考试次数")
+	ErrAttemptNotFound    = errors.New("答题记录不存在")
+	ErrAttemptExpired     = errors.New("作答时间已结束")
+	ErrAttemptSubmitted   = errors.New("试卷已提交")
+	ErrQuestionNotInExam  = errors.New("题目不属于本场考试")
+	ErrInvalidAnswer      = errors.New("作答格式错误")
+	ErrInvalidExam        = errors.New("考试配置无效")
+	ErrExamInUse          = errors.New("考试仍有作答中或未判完的答卷，无法删除")
+)
+
+// AnswerInput 单题作答
+type AnswerInput struct {
+	QuestionID uint            `json:"question_id" binding:"required"`
+	Answer     json.RawMessage `json:"answer" binding:"required"`
+}
+
+// AttemptHook 交卷后在同一事务内执行的处理，如自动判分
+type AttemptHook func(tx *gorm.DB, attempt *ExamAttempt) error
+
+type ExamService struct {
+	db       *gorm.DB
+	onFinish []AttemptHook
+}
+
+func NewExamService(db *gorm.DB) *ExamService {
+	return &ExamService{db: db}
+}
+
+// OnFinish 注册交卷后的处理，按注册顺序执行
+func (s *ExamService) OnFinish(hooks ...AttemptHook) *ExamService {
+	s.onFinish = append(s.onFinish, hooks...)
+	return s
+}
+
+// GetExam 获取考试及其完整配置
+func (s *ExamService) GetExam(examID uint) (*Exam, error) {
+	var exam Exam
+	if err := s.db.Preload("QuestionConfigs").
+		Preload("Prerequisites").
+		Preload("ScoreRules").
+		First(&exam, examID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrExamNotFound
+		}
+		return nil, err
+	}
+	return &exam, nil
+}
+
+// CreateExam 在同一事务中创建考试及其试题配置、前置条件和分数规则
+func (s *ExamService) CreateExam(exam *Exam) error {
+	if err := exam.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidExam, err)
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := exam.ValidateReferences(tx); err != nil {
+			return wrapInvalidExam(err)
+		}
+		return tx.Create(exam).Error
+	})
+}
+
+// UpdateExam 用新的配置整体替换考试。旧的子配置软删除后重建，
+// 以便按开考时间重新生成历史试卷
+func (s *ExamService) UpdateExam(examID uint, exam *Exam) (*Exam, error) {
+	if _, err := s.GetExam(examID); err != nil {
+		return nil, err
+	}
+	exam.ID = examID
+	if err := exam.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExam, err)
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := exam.ValidateReferences(tx); err != nil {
+			return wrapInvalidExam(err)
+		}
+
+		if err := tx.Model(&Exam{}).Where("id = ?", examID).
+			Select("name", "description", "start_time", "end_time", "duration", "max_attempts",
+				"passing_score", "belongs_type", "belongs_id", "multiple_choice_scoring", "blank_normalize",
+				"review_policy").
+			Updates(exam).Error; err != nil {
+			return err
+		}
+
+		if err := deleteExamChildren(tx, examID); err != nil {
+			return err
+		}
+		for i := range exam.QuestionConfigs {
+			exam.QuestionConfigs[i].ID = 0
+			exam.QuestionConfigs[i].ExamID = examID
+		}
+		for i := range exam.Prerequisites {
+			exam.Prerequisites[i].ID = 0
+			exam.Prerequisites[i].ExamID = examID
+		}
+		for i := range exam.ScoreRules {
+			exam.ScoreRules[i].ID = 0
+			exam.ScoreRules[i].ExamID = examID
+		}
+		if err := tx.Omit(clause.Associations).Create(&exam.QuestionConfigs).Error; err != nil {
+			return err
+		}
+		if len(exam.Prerequisites) > 0 {
+			if err := tx.Omit(clause.Associations).Create(&exam.Prerequisites).Error; err != nil {
+				return err
+			}
+		}
+		if len(exam.ScoreRules) > 0 {
+			if err := tx.Create(&exam.ScoreRules).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetExam(examID)
+}
+
+// DeleteExam 软删除考试及其配置；仍有考生作答中或答卷尚未判完时拒绝删除
+func (s *ExamService) DeleteExam(examID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var exam Exam
+		if err := tx.First(&exam, examID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrExamNotFound
+			}
+			return err
+		}
+
+		var active int64
+		if err := tx.Model(&ExamAttempt{}).
+			Where("exam_id = ? AND status IN ?", examID, unfinishedAttemptStatuses).
+			Count(&active).Error; err != nil {
+			return err
+		}
+		if active > 0 {
+			return ErrExamInUse
+		}
+
+		if err := deleteExamChildren(tx, examID); err != nil {
+			return err
+		}
+		return tx.Delete(&exam).Error
+	})
+}
+
+func deleteExamChildren(tx *gorm.DB, examID uint) error {
+	for _, model := range []any{&ExamQuestionConfig{}, &ExamPrerequisite{}, &ExamScoreRule{}} {
+		if err := tx.Where("exam_id = ?", examID).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func wrapInvalidExam(err error) error {
+	if errors.Is(err, ErrInsufficientQuestions) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ErrInvalidExam, err)
+}
+
+// StartAttempt 开始考试；已有未超时的作答时直接返回该记录
+func (s *ExamService) StartAttempt(userID, examID uint) (*ExamAttempt, error) {
+	var exam Exam
+	if err := s.db.Preload("QuestionConfigs", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).First(&exam, examID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrExamNotFound
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if now.Before(exam.StartTime) {
+		return nil, ErrExamNotStarted
+	}
+	if !now.Before(exam.EndTime) {
+		return nil, ErrExamClosed
+	}
+
+	var attempt ExamAttempt
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 锁定用户行，避免并发开考绕过次数限制
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").First(&User{}, userID).Error; err != nil {
+			return err
+		}
+
+		var current ExamAttempt
+		err := tx.Where("user_id = ? AND exam_id = ? AND status = ?", userID, examID, AttemptStatusInProgress).
+			First(&current).Error
+		if err == nil {
+			if !current.IsExpired(now) {
+				attempt = current
+				return nil
+			}
+			if err := s.finishAttempt(tx, &current, now); err != nil {
+				return err
+			}
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if err := CheckPrerequisites(tx, userID, examID); err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&ExamAttempt{}).
+			Where("user_id = ? AND exam_id = ?", userID, examID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if int(count) >= exam.MaxAttempts {
+			return ErrMaxAttemptsReached
+		}
+
+		deadline := now.Add(time.Duration(exam.Duration) * time.Minute)
+		if exam.EndTime.Before(deadline) {
+			deadline = exam.EndTime
+		}
+		seed := NewPaperSeed()
+		paper, err := GeneratePaper(tx, exam.QuestionConfigs, seed, now)
+		if err != nil {
+			return err
+		}
+
+		attempt = ExamAttempt{
+			UserID:    userID,
+			ExamID:    examID,
+			StartTime: now,
+			Deadline:  deadline,
+			Status:    AttemptStatusInProgress,
+			Seed:      seed,
+			Paper:     paper,
+		}
+		return tx.Omit(clause.Associations).Create(&attempt).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// GetAttempt 获取考生本人的答题记录
+func (s *ExamService) GetAttempt(userID, attemptID uint) (*ExamAttempt, error) {
+	var attempt ExamAttempt
+	if err := s.db.Preload("Answers").
+		Where("id = ? AND user_id = ?", attemptID, userID).
+		First(&attempt).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAttemptNotFound
+		}
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// SaveAnswers 增量保存作答，同一题重复提交时覆盖之前的答案
+func (s *ExamService) SaveAnswers(userID, attemptID uint, inputs []AnswerInput) error {
+	now := time.Now()
+	attempt, err := s.writableAttempt(userID, attemptID, now)
+	if err != nil {
+		return err
+	}
+
+	questions, err := s.attemptQuestions(attempt, inputs)
+	if err != nil {
+		return err
+	}
+
+	answers := make([]ExamAnswer, 0, len(inputs))
+	for _, in := range inputs {
+		q := questions[in.QuestionID]
+		normalized, err := q.NormalizeAnswer(in.Answer)
+		if err != nil {
+			return fmt.Errorf("%w: 第 %d 题 %v", ErrInvalidAnswer, in.QuestionID, err)
+		}
+		answers = append(answers, ExamAnswer{
+			AttemptID:  attempt.ID,
+			QuestionID: in.QuestionID,
+			UserAnswer: normalized,
+		})
+	}
+	if len(answers) == 0 {
+		return nil
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// 写入前再次确认状态，防止与交卷/自动交卷并发
+		var current ExamAttempt
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&current, attempt.ID).Error; err != nil {
+			return err
+		}
+		if current.Status != AttemptStatusInProgress {
+			return ErrAttemptSubmitted
+		}
+		return tx.Omit(clause.Associations).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "attempt_id"}, {Name: "question_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"user_answer", "updated_at"}),
+		}).Create(&answers).Error
+	})
+}
+
+// SubmitAttempt 考生交卷；超过截止时间交卷按自动交卷处理
+func (s *ExamService) SubmitAttempt(userID, attemptID uint) (*ExamAttempt, error) {
+	attempt, err := s.GetAttempt(userID, attemptID)
+	if err != nil {
+		return nil, err
+	}
+	if attempt.Status != AttemptStatusInProgress {
+		return nil, ErrAttemptSubmitted
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		return s.finishAttempt(tx, attempt, time.Now())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetAttempt(userID, attemptID)
+}
+
+// SweepExpiredAttempts 将已超时仍在作答中的记录自动交卷，返回处理数量
+func (s *ExamService) SweepExpiredAttempts(now time.Time) (int, error) {
+	var attempts []ExamAttempt
+	if err := s.db.Where("status = ? AND deadline <= ?", AttemptStatusInProgress, now).
+		Find(&attempts).Error; err != nil {
+		return 0, err
+	}
+
+	swept := 0
+	for i := range attempts {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			return s.finishAttempt(tx, &attempts[i], now)
+		})
+		if err != nil {
+			return swept, err
+		}
+		swept++
+	}
+	return swept, nil
+}
+
+// writableAttempt 返回仍可作答的记录；已超时的记录会被自动交卷
+func (s *ExamService) writableAttempt(userID, attemptID uint, now time.Time) (*ExamAttempt, error) {
+	attempt, err := s.GetAttempt(userID, attemptID)
+	if err != nil {
+		return nil, err
+	}
+	if attempt.Status != AttemptStatusInProgress {
+		return nil, ErrAttemptSubmitted
+	}
+
+	var exam Exam
+	if err := s.db.Select("id", "end_time").First(&exam, attempt.ExamID).Error; err != nil {
+		return nil, err
+	}
+	if attempt.IsExpired(now) || !now.Before(exam.EndTime) {
+		if err := s.db.Transaction(func(tx *gorm.DB) error {
+			return s.finishAttempt(tx, attempt, now)
+		}); err != nil {
+			return nil, err
+		}
+		return nil, ErrAttemptExpired
+	}
+	return attempt, nil
+}
+
+// attemptQuestions 加载作答涉及的题目，并确认其在本次抽到的试卷中
+func (s *ExamService) attemptQuestions(attempt *ExamAttempt, inputs []AnswerInput) (map[uint]*Question, error) {
+	ids := make([]uint, 0, len(inputs))
+	for _, in := range inputs {
+		if !attempt.Paper.Contains(in.QuestionID) {
+			return nil, fmt.Errorf("%w: %d", ErrQuestionNotInExam, in.QuestionID)
+		}
+		ids = append(ids, in.QuestionID)
+	}
+
+	// 抽题后题目可能被删除，作答仍以试卷为准
+	var questions []Question
+	if err := s.db.Unscoped().Where("id IN ?", ids).Find(&questions).Error; err != nil {
+		return nil, err
+	}
+
+	result := make(map[uint]*Question, len(questions))
+	for i := range questions {
+		result[questions[i].ID] = &questions[i]
+	}
+	for _, id := range ids {
+		if _, ok := result[id]; !ok {
+			return nil, fmt.Errorf("%w: %d", ErrQuestionNotInExam, id)
+		}
+	}
+	return result, nil
+}
+
+// RegeneratePaper 用记录中的种子与开考时间重新抽题，用于复核或争议处理
+func (s *ExamService) RegeneratePaper(attemptID uint) (*ExamAttempt, Paper, error) {
+	var attempt ExamAttempt
+	if err := s.db.First(&attempt, attemptID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrAttemptNotFound
+		}
+		return nil, nil, err
+	}
+
+	var configs []ExamQuestionConfig
+	if err := s.db.Unscoped().
+		Where("exam_id = ? AND created_at <= ?", attempt.ExamID, attempt.StartTime).
+		Where("deleted_at IS NULL OR deleted_at > ?", attempt.StartTime).
+		Find(&configs).Error; err != nil {
+		return nil, nil, err
+	}
+
+	paper, err := GeneratePaper(s.db, configs, attempt.Seed, attempt.StartTime)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &attempt, paper, nil
+}
+
+// finishAttempt 交卷；仅当记录仍在作答中时生效，重复调用无副作用
+func (s *ExamService) finishAttempt(tx *gorm.DB, attempt *ExamAttempt, now time.Time) error {
+	endTime := now
+	auto := attempt.IsExpired(now)
+	if auto {
+		endTime = attempt.Deadline
+	}
+
+	result := tx.Model(&ExamAttempt{}).
+		Where("id = ? AND status = ?", attempt.ID, AttemptStatusInProgress).
+		Updates(map[string]any{
+			"status":         AttemptStatusSubmitted,
+			"end_time":       endTime,
+			"auto_submitted": auto,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		// 已被其他请求交卷
+		return nil
+	}
+
+	attempt.Status = AttemptStatusSubmitted
+	attempt.EndTime = endTime
+	attempt.AutoSubmitted = auto
+	for _, hook := range s.onFinish {
+		if err := hook(tx, attempt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
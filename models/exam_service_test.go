@@ -0,0 +1,45 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDeleteExamRefusesUngradedAttempts(t *testing.T) {
+	db := openTestDB(t)
+	course := Course{Name: "安全生产", EnrollmentCode: "c1"}
+	db.Create(&course)
+	bank := QuestionBank{Name: "安全题库", QuestionType: QuestionTypeSubjective}
+	db.Create(&bank)
+	db.Create(&Question{BankID: bank.ID, Type: QuestionTypeSubjective, Content: "简答", Difficulty: 3, Score: 10,
+		Answers: JSONB{}, Reference: "参考答案"})
+
+	now := time.Now()
+	exam := Exam{
+		Name: "结业考试", StartTime: now.Add(-time.Hour), EndTime: now.Add(time.Hour), Duration: 30, MaxAttempts: 3,
+		BelongsType: ExamBelongsCourse, BelongsID: course.ID,
+		QuestionConfigs: []ExamQuestionConfig{{QuestionBankID: bank.ID, Amount: 1}},
+	}
+	svc := NewExamService(db)
+	if err := svc.CreateExam(&exam); err != nil {
+		t.Fatal(err)
+	}
+	attempt := ExamAttempt{UserID: 1, ExamID: exam.ID, StartTime: now, Deadline: now.Add(time.Hour)}
+	db.Create(&attempt)
+
+	for _, status := range []string{AttemptStatusInProgress, AttemptStatusSubmitted, AttemptStatusPending} {
+		db.Model(&attempt).Update("status", status)
+		if err := svc.DeleteExam(exam.ID); !errors.Is(err, ErrExamInUse) {
+			t.Fatalf("status=%s: got %v，应拒绝删除", status, err)
+		}
+	}
+
+	db.Model(&attempt).Update("status", AttemptStatusGraded)
+	if err := svc.DeleteExam(exam.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.GetExam(exam.ID); !errors.Is(err, ErrExamNotFound) {
+		t.Fatalf("删除后仍能查到考试: %v", err)
+	}
+}
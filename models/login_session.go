@@ -0,0 +1,238 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginSession 一台设备上的一次登录。刷新令牌每次使用后轮换，同一会话内轮换出的令牌属于同一族，
+// 检测到已轮换的令牌被再次使用时吊销整个会话。
+type LoginSession struct {
+	ID           string     `gorm:"type:varchar(32);primaryKey" json:"id"`
+	UserID       uint       `gorm:"not null;index" json:"-"`
+	Device       string     `gorm:"type:varchar(100)" json:"device"`
+	IP           string     `gorm:"type:varchar(45)" json:"ip"`
+	TokenVersion uint       `json:"-"` // 登录时用户的令牌版本，修改密码等操作提升版本后会话失效
+	LastUsedAt   time.Time  `json:"last_used_at"`
+	ExpiresAt    time.Time  `gorm:"index" json:"expires_at"` // 每次轮换刷新令牌时顺延
+	RevokedAt    *time.Time `json:"-"`
+	RevokeReason string     `gorm:"type:varchar(20)" json:"-"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// RefreshToken 刷新令牌，只保存哈希
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey"`
+	SessionID string     `gorm:"type:varchar(32);not null;index"`
+	TokenHash string     `gorm:"type:char(64);not null;uniqueIndex"`
+	ExpiresAt time.Time  `gorm:"not null;index"`
+	RotatedAt *time.Time // 已换发新令牌的时间，非空时再次使用视为泄露
+	CreatedAt time.Time
+}
+
+// 会话吊销原因
+const (
+	RevokeLogout   = "logout"   // 用户退出登录
+	RevokeReuse    = "reuse"    // 检测到刷新令牌重复使用
+	RevokeVersion  = "version"  // 用户令牌版本已提升
+	RevokeDisabled = "disabled" // 账号被停用
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("刷新令牌无效或已过期，请重新登录")
+	ErrRefreshTokenReused  = errors.New("刷新令牌已被使用，该设备已退出登录")
+	ErrSessionNotFound     = errors.New("登录会话不存在")
+	ErrUserDisabled        = errors.New("账号未通过审核")
+)
+
+// CreateLoginSession 为用户创建登录会话并签发第一个刷新令牌
+func CreateLoginSession(db *gorm.DB, user *User, device, ip string, ttl time.Duration) (*LoginSession, string, error) {
+	id, err := randomToken(16)
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	session := LoginSession{
+		ID:           hex.EncodeToString(id),
+		UserID:       user.ID,
+		Device:       device,
+		IP:           ip,
+		TokenVersion: user.TokenVersion,
+		LastUsedAt:   now,
+		ExpiresAt:    now.Add(ttl),
+	}
+
+	var token string
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		token, err = issueRefreshToken(tx, session.ID, session.ExpiresAt)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return &session, token, nil
+}
+
+// RotateRefreshToken 用刷新令牌换取新的刷新令牌，旧令牌随即失效并顺延会话有效期。
+// 已轮换的令牌再次出现说明令牌可能泄露，吊销整个会话并返回 ErrRefreshTokenReused。
+func RotateRefreshToken(db *gorm.DB, token string, ttl time.Duration) (*LoginSession, *User, string, error) {
+	var (
+		session  LoginSession
+		user     User
+		newToken string
+		result   error // 需要提交吊销结果的错误，不能通过回滚事务返回
+	)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var rt RefreshToken
+		err := tx.Where("token_hash = ?", hashToken(token)).First(&rt).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			result = ErrRefreshTokenInvalid
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND revoked_at IS NULL", rt.SessionID).First(&session).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			result = ErrRefreshTokenInvalid
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if !now.Before(rt.ExpiresAt) || !now.Before(session.ExpiresAt) {
+			result = ErrRefreshTokenInvalid
+			return nil
+		}
+		// 轮换以条件更新完成，并发使用同一令牌时只有一个请求能成功，其余按重复使用处理
+		res := tx.Model(&RefreshToken{}).Where("id = ? AND rotated_at IS NULL", rt.ID).Update("rotated_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			result = ErrRefreshTokenReused
+			return revokeSession(tx, &session, RevokeReuse, now)
+		}
+
+		err = tx.First(&user, session.UserID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			result = ErrRefreshTokenInvalid
+			return revokeSession(tx, &session, RevokeVersion, now)
+		}
+		if err != nil {
+			return err
+		}
+		if user.TokenVersion != session.TokenVersion {
+			result = ErrRefreshTokenInvalid
+			return revokeSession(tx, &session, RevokeVersion, now)
+		}
+		if !user.Status {
+			result = ErrUserDisabled
+			return revokeSession(tx, &session, RevokeDisabled, now)
+		}
+
+		session.LastUsedAt, session.ExpiresAt = now, now.Add(ttl)
+		if err := tx.Model(&session).Updates(map[string]any{
+			"last_used_at": session.LastUsedAt, "expires_at": session.ExpiresAt,
+		}).Error; err != nil {
+			return err
+		}
+		newToken, err = issueRefreshToken(tx, session.ID, session.ExpiresAt)
+		return err
+	})
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if result != nil {
+		return nil, nil, "", result
+	}
+	return &session, &user, newToken, nil
+}
+
+// RevokeLoginSession 吊销用户的登录会话，会话不存在或已吊销时返回 ErrSessionNotFound
+func RevokeLoginSession(db *gorm.DB, userID uint, sessionID, reason string) error {
+	res := db.Model(&LoginSession{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Updates(map[string]any{"revoked_at": time.Now(), "revoke_reason": reason})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// ActiveLoginSessions 返回用户未过期且未吊销的登录会话，最近使用的在前
+func ActiveLoginSessions(db *gorm.DB, userID uint) ([]LoginSession, error) {
+	var sessions []LoginSession
+	err := db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+// IsSessionActive 判断访问令牌所属的会话是否仍然有效
+func IsSessionActive(db *gorm.DB, sessionID string, userID uint) (bool, error) {
+	var n int64
+	err := db.Model(&LoginSession{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > ?", sessionID, userID, time.Now()).
+		Count(&n).Error
+	return n > 0, err
+}
+
+// DeleteExpiredLoginSessions 删除已过期的会话与刷新令牌，返回删除的会话数
+func DeleteExpiredLoginSessions(db *gorm.DB, now time.Time) (int64, error) {
+	var n int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		expired := tx.Model(&LoginSession{}).Select("id").Where("expires_at <= ?", now)
+		if err := tx.Where("session_id IN (?) OR expires_at <= ?", expired, now).
+			Delete(&RefreshToken{}).Error; err != nil {
+			return err
+		}
+		res := tx.Where("expires_at <= ?", now).Delete(&LoginSession{})
+		n = res.RowsAffected
+		return res.Error
+	})
+	return n, err
+}
+
+func revokeSession(tx *gorm.DB, session *LoginSession, reason string, now time.Time) error {
+	return tx.Model(session).Updates(map[string]any{"revoked_at": now, "revoke_reason": reason}).Error
+}
+
+func issueRefreshToken(tx *gorm.DB, sessionID string, expiresAt time.Time) (string, error) {
+	b, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	err = tx.Create(&RefreshToken{SessionID: sessionID, TokenHash: hashToken(token), ExpiresAt: expiresAt}).Error
+	return token, err
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRotateRefreshToken(t *testing.T) {
+	db := openTestDB(t)
+	user := User{Name: "张三", Role: "user"}
+	db.Create(&user)
+
+	active := func(id string) bool {
+		ok, err := IsSessionActive(db, id, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+
+	// 轮换：旧令牌换出新令牌，会话不变
+	session, t1, err := CreateLoginSession(db, &user, "iPhone", "127.0.0.1", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, u, t2, err := RotateRefreshToken(db, t1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ID != session.ID || u.ID != user.ID || t2 == "" || t2 == t1 {
+		t.Fatalf("轮换结果不正确: session=%s user=%d token=%q", s.ID, u.ID, t2)
+	}
+	_, _, t3, err := RotateRefreshToken(db, t2, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 重复使用已轮换的令牌：吊销整个会话，最新的令牌也随之失效
+	if _, _, _, err := RotateRefreshToken(db, t1, time.Hour); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("重复使用 err = %v", err)
+	}
+	if _, _, _, err := RotateRefreshToken(db, t3, time.Hour); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Fatalf("会话吊销后 err = %v", err)
+	}
+	if active(session.ID) {
+		t.Fatal("重复使用后会话仍然有效")
+	}
+
+	// 过期：令牌无效，但不按重复使用处理
+	expired, t4, err := CreateLoginSession(db, &user, "iPad", "127.0.0.1", -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, _, _, err := RotateRefreshToken(db, t4, time.Hour); !errors.Is(err, ErrRefreshTokenInvalid) {
+			t.Fatalf("过期令牌 err = %v", err)
+		}
+	}
+	var revoked LoginSession
+	db.First(&revoked, "id = ?", expired.ID)
+	if revoked.RevokedAt != nil {
+		t.Fatalf("过期令牌不应吊销会话: %s", revoked.RevokeReason)
+	}
+
+	// 提升令牌版本：会话失效
+	bumped, t5, err := CreateLoginSession(db, &user, "Mac", "127.0.0.1", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Model(&user).Update("token_version", user.TokenVersion+1)
+	if _, _, _, err := RotateRefreshToken(db, t5, time.Hour); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Fatalf("令牌版本提升后 err = %v", err)
+	}
+	if active(bumped.ID) {
+		t.Fatal("令牌版本提升后会话仍然有效")
+	}
+
+	// 停用账号：会话失效
+	disabled, t6, err := CreateLoginSession(db, &user, "PC", "127.0.0.1", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Model(&user).Update("status", false)
+	if _, _, _, err := RotateRefreshToken(db, t6, time.Hour); !errors.Is(err, ErrUserDisabled) {
+		t.Fatalf("账号停用后 err = %v", err)
+	}
+	if active(disabled.ID) {
+		t.Fatal("账号停用后会话仍然有效")
+	}
+}
@@ -2,6 +2,12 @@ package models
 
 import "gorm.io/gorm"
 
-func AutoMigrate(db *gorm.DB) {
-	db.AutoMigrate(&User{}, &Company{}, &Course{}, &CourseUnit{}, &Video{})
+func AutoMigrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&User{}, &Company{}, &Course{}, &CourseUnit{}, &Video{}, &QuestionBank{}, &Question{},
+		&Exam{}, &ExamQuestionConfig{}, &ExamScoreRule{}, &ExamPrerequisite{}, &ExamAttempt{}, &ExamAnswer{},
+		&Enrollment{}, &UserVideoProgress{}, &VideoWatchSession{}, &UploadSession{}, &VideoSubtitle{}, &VideoChapter{},
+		&LoginSession{}, &RefreshToken{}); err != nil {
+		return err
+	}
+	return MigrateUserCourses(db)
 }
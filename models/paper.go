@@ -0,0 +1,180 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	mrand "math/rand"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrInsufficientQuestions = errors.New("题库题目数量不足")
+
+// PaperItem 试卷中的一道题及其选项展示顺序
+type PaperItem struct {
+	QuestionID  uint     `json:"question_id"`
+	OptionOrder []string `json:"option_order,omitempty"`
+}
+
+// Paper 抽题结果，按作答顺序排列
+type Paper []PaperItem
+
+func (p *Paper) Scan(value any) error {
+	var bytes []byte
+	switch v := value.(type) {
+	case nil:
+		*p = nil
+		return nil
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return errors.New("类型断言失败")
+	}
+	return json.Unmarshal(bytes, p)
+}
+
+func (p Paper) Value() (driver.Value, error) {
+	if p == nil {
+		return nil, nil
+	}
+	return json.Marshal(p)
+}
+
+// QuestionIDs 返回试卷包含的题目ID
+func (p Paper) QuestionIDs() []uint {
+	ids := make([]uint, len(p))
+	for i, item := range p {
+		ids[i] = item.QuestionID
+	}
+	return ids
+}
+
+// Contains 判断题目是否在试卷中
+func (p Paper) Contains(questionID uint) bool {
+	for _, item := range p {
+		if item.QuestionID == questionID {
+			return true
+		}
+	}
+	return false
+}
+
+// NewPaperSeed 生成随机抽题种子
+func NewPaperSeed() int64 {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return time.Now().UnixNano()
+	}
+	return int64(binary.BigEndian.Uint64(b[:]) >> 1)
+}
+
+// GeneratePaper 按抽题配置从题库抽取题目，并打乱题目与选项顺序。
+// 题库以 asOf 时刻的状态为准（该时刻之后新增或之前已删除的题目不参与抽取），
+// 因此相同的种子与时刻总能得到相同的试卷。
+func GeneratePaper(db *gorm.DB, configs []ExamQuestionConfig, seed int64, asOf time.Time) (Paper, error) {
+	rng := mrand.New(mrand.NewSource(seed))
+
+	// 按配置ID排序，保证抽题顺序与配置录入顺序无关
+	sorted := make([]ExamQuestionConfig, len(configs))
+	copy(sorted, configs)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	drawn := make(map[uint]bool)
+	var questions []Question
+	for _, cfg := range sorted {
+		query := db.Unscoped().
+			Where("bank_id = ? AND created_at <= ?", cfg.QuestionBankID, asOf).
+			Where("deleted_at IS NULL OR deleted_at > ?", asOf)
+		if cfg.QuestionType != "" {
+			query = query.Where("type = ?", cfg.QuestionType)
+		}
+		if cfg.Difficulty != nil {
+			query = query.Where("difficulty = ?", *cfg.Difficulty)
+		}
+
+		var candidates []Question
+		if err := query.Order("id ASC").Find(&candidates).Error; err != nil {
+			return nil, err
+		}
+
+		// 多条配置指向同一题库时避免重复抽题
+		pool := candidates[:0]
+		for _, q := range candidates {
+			if !drawn[q.ID] {
+				pool = append(pool, q)
+			}
+		}
+		if len(pool) < cfg.Amount {
+			return nil, fmt.Errorf("%w: 题库 %d（题型 %s，难度 %s）可用 %d 道，需要 %d 道",
+				ErrInsufficientQuestions, cfg.QuestionBankID, displayOrAny(cfg.QuestionType),
+				displayDifficulty(cfg.Difficulty), len(pool), cfg.Amount)
+		}
+
+		for _, idx := range rng.Perm(len(pool))[:cfg.Amount] {
+			drawn[pool[idx].ID] = true
+			questions = append(questions, pool[idx])
+		}
+	}
+
+	rng.Shuffle(len(questions), func(i, j int) {
+		questions[i], questions[j] = questions[j], questions[i]
+	})
+
+	paper := make(Paper, 0, len(questions))
+	for _, q := range questions {
+		item := PaperItem{QuestionID: q.ID}
+		if q.Type == QuestionTypeSingleChoice || q.Type == QuestionTypeMultipleChoice {
+			keys := make([]string, 0, len(q.Options))
+			for k := range q.Options {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			rng.Shuffle(len(keys), func(i, j int) {
+				keys[i], keys[j] = keys[j], keys[i]
+			})
+			item.OptionOrder = keys
+		}
+		paper = append(paper, item)
+	}
+	return paper, nil
+}
+
+// Equal 判断两份试卷的题目与选项顺序是否完全一致
+func (p Paper) Equal(other Paper) bool {
+	if len(p) != len(other) {
+		return false
+	}
+	for i := range p {
+		if p[i].QuestionID != other[i].QuestionID || len(p[i].OptionOrder) != len(other[i].OptionOrder) {
+			return false
+		}
+		for j := range p[i].OptionOrder {
+			if p[i].OptionOrder[j] != other[i].OptionOrder[j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func displayOrAny(s string) string {
+	if s == "" {
+		return "不限"
+	}
+	return s
+}
+
+func displayDifficulty(d *int) string {
+	if d == nil {
+		return "不限"
+	}
+	return fmt.Sprint(*d)
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestGeneratePaperAsOf(t *testing.T) {
+	db := openTestDB(t)
+	bank := QuestionBank{Name: "安全题库", QuestionType: QuestionTypeSingleChoice}
+	db.Create(&bank)
+	newQuestion := func(i int) *Question {
+		q := &Question{BankID: bank.ID, Type: QuestionTypeSingleChoice, Content: fmt.Sprint("第 ", i, " 题"), Difficulty: 3,
+			Options: JSONB{"A": "甲", "B": "乙", "C": "丙", "D": "丁"}, Answers: JSONB{"keys": []string{"A"}}}
+		if err := db.Create(q).Error; err != nil {
+			t.Fatal(err)
+		}
+		return q
+	}
+	var questions []*Question
+	for i := 1; i <= 10; i++ {
+		questions = append(questions, newQuestion(i))
+	}
+	// 抽题前已删除的题目不参与抽取
+	removed := newQuestion(0)
+	db.Delete(removed)
+
+	difficulty := 3
+	configs := []ExamQuestionConfig{
+		{Model: gorm.Model{ID: 2}, QuestionBankID: bank.ID, QuestionType: QuestionTypeSingleChoice, Amount: 3},
+		{Model: gorm.Model{ID: 1}, QuestionBankID: bank.ID, Difficulty: &difficulty, Amount: 4},
+	}
+	seed := NewPaperSeed()
+	asOf := time.Now()
+
+	first, err := GeneratePaper(db, configs, seed, asOf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 7 || first.Contains(removed.ID) {
+		t.Fatalf("试卷题目: %v", first.QuestionIDs())
+	}
+	second, err := GeneratePaper(db, configs, seed, asOf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first.Equal(second) {
+		t.Fatalf("相同种子与时刻生成的试卷不一致:\n%v\n%v", first, second)
+	}
+
+	// asOf 之后题库的变化不影响重新生成的试卷
+	for i := 11; i <= 15; i++ {
+		newQuestion(i)
+	}
+	for _, q := range questions[:5] {
+		db.Delete(q)
+	}
+	regenerated, err := GeneratePaper(db, configs, seed, asOf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first.Equal(regenerated) {
+		t.Fatalf("题库变化后重新生成的试卷不一致:\n%v\n%v", first, regenerated)
+	}
+
+	// 当前时刻的题库已不同，抽出的试卷随之变化
+	current, err := GeneratePaper(db, configs, seed, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Equal(current) {
+		t.Fatal("按当前题库生成的试卷不应与开考时相同")
+	}
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// CourseProgress 返回用户在课程中的学习进度（0-100），即报名记录中的进度，未报名时为 0。
+// 进度由 CourseService.EvaluateCompletion 维护，已完成的课程记为 100。
+func CourseProgress(db *gorm.DB, userID, courseID uint) (int, error) {
+	enrollment, err := FindEnrollment(db, userID, courseID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if enrollment.IsCompleted {
+		return 100, nil
+	}
+	return int(enrollment.Progress), nil
+}
+
+// completedMandatoryVideos 统计用户已看完的课程必修视频数
+func completedMandatoryVideos(db *gorm.DB, userID, courseID uint) (int64, error) {
+	var completed int64
+	err := db.Model(&UserVideoProgress{}).
+		Joins("JOIN enrollments ON enrollments.id = user_video_progresses.enrollment_id").
+		Joins("JOIN videos ON videos.id = user_video_progresses.video_id").
+		Where("enrollments.user_id = ? AND enrollments.course_id = ? AND enrollments.deleted_at IS NULL", userID, courseID).
+		Where("videos.course_id = ? AND videos.is_mandatory = ? AND videos.deleted_at IS NULL", courseID, true).
+		Where("user_video_progresses.is_completed = ?", true).
+		Distinct("user_video_progresses.video_id").
+		Count(&completed).Error
+	return completed, err
+}
+
+// UnmetPrerequisite 未满足的考试前置条件
+type UnmetPrerequisite struct {
+	CourseID    uint   `json:"course_id"`
+	CourseName  string `json:"course_name"`
+	MinProgress int    `json:"min_progress"`
+	Progress    int    `json:"progress"`
+}
+
+// PrerequisiteError 开考前置条件未满足，列出所有未达标的课程
+type PrerequisiteError struct {
+	Unmet []UnmetPrerequisite
+}
+
+func (e *PrerequisiteError) Error() string {
+	names := make([]string, len(e.Unmet))
+	for i, u := range e.Unmet {
+		names[i] = fmt.Sprintf("%s（%d%%/%d%%）", u.CourseName, u.Progress, u.MinProgress)
+	}
+	return "未满足考试前置条件: " + strings.Join(names, "、")
+}
+
+// CheckPrerequisites 校验用户是否满足考试的全部前置课程进度要求
+func CheckPrerequisites(db *gorm.DB, userID, examID uint) error {
+	var prerequisites []ExamPrerequisite
+	if err := db.Preload("Course").
+		Where("exam_id = ?", examID).
+		Order("id ASC").
+		Find(&prerequisites).Error; err != nil {
+		return err
+	}
+
+	var unmet []UnmetPrerequisite
+	for _, p := range prerequisites {
+		progress, err := CourseProgress(db, userID, p.CourseID)
+		if err != nil {
+			return err
+		}
+		if progress < p.MinProgress {
+			unmet = append(unmet, UnmetPrerequisite{
+				CourseID:    p.CourseID,
+				CourseName:  p.Course.Name,
+				MinProgress: p.MinProgress,
+				Progress:    progress,
+			})
+		}
+	}
+	if len(unmet) > 0 {
+		return &PrerequisiteError{Unmet: unmet}
+	}
+	return nil
+}
@@ -4,22 +4,52 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strconv"
 
 	"gorm.io/gorm"
 )
 
+// 题目类型
+const (
+	QuestionTypeSingleChoice   = "single_choice"
+	QuestionTypeMultipleChoice = "multiple_choice"
+	QuestionTypeFillBlank      = "fill_blank"
+	QuestionTypeSubjective     = "subjective"
+)
+
+// IsValidQuestionType 判断题型是否受支持
+func IsValidQuestionType(t string) bool {
+	switch t {
+	case QuestionTypeSingleChoice, QuestionTypeMultipleChoice, QuestionTypeFillBlank, QuestionTypeSubjective:
+		return true
+	}
+	return false
+}
+
 // 自定义 JSON 类型（通用处理方案）
 type JSONB map[string]any
 
 func (j *JSONB) Scan(value any) error {
-	bytes, ok := value.([]byte)
-	if !ok {
+	var bytes []byte
+	switch v := value.(type) {
+	case nil:
+		*j = nil
+		return nil
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
 		return errors.New("类型断言失败")
 	}
 	return json.Unmarshal(bytes, &j)
 }
 
 func (j JSONB) Value() (driver.Value, error) {
+	if j == nil {
+		return nil, nil
+	}
 	return json.Marshal(j)
 }
 
@@ -32,6 +62,11 @@ type QuestionBank struct {
 }
 
 // 题目表（修正版）
+//
+// 各题型的 JSON 字段约定：
+//   - 选择题：Options 为 {"A": "选项内容", ...}，Answers 为 {"keys": ["A"]}
+//   - 填空题：Answers 为 {"blanks": 空数}，Candidates 为 {"1": ["答案", "同义答案"], ...}，按空位（从 1 开始）给出候选答案
+//   - 主观题：Answers 为 {}，Reference 必填
 type Question struct {
 	gorm.Model
 	BankID     uint   `gorm:"not null;index"`
@@ -49,3 +84,193 @@ type Question struct {
 
 	Bank QuestionBank `gorm:"foreignKey:BankID"`
 }
+
+// Validate 按题型校验题目结构
+func (q *Question) Validate() error {
+	if q.Content == "" {
+		return errors.New("题目内容不能为空")
+	}
+	if q.Difficulty < 1 || q.Difficulty > 5 {
+		return errors.New("难度必须在 1 到 5 之间")
+	}
+	if q.Score < 0 {
+		return errors.New("分值不能为负数")
+	}
+
+	switch q.Type {
+	case QuestionTypeSingleChoice, QuestionTypeMultipleChoice:
+		return q.validateChoice()
+	case QuestionTypeFillBlank:
+		return q.validateFillBlank()
+	case QuestionTypeSubjective:
+		if q.Reference == "" {
+			return errors.New("主观题必须提供参考答案")
+		}
+		if q.Answers == nil {
+			q.Answers = JSONB{}
+		}
+		return nil
+	default:
+		return fmt.Errorf("无效的题目类型: %s", q.Type)
+	}
+}
+
+func (q *Question) validateChoice() error {
+	if len(q.Options) < 2 {
+		return errors.New("选择题至少需要两个选项")
+	}
+	for key, text := range q.Options {
+		if s, ok := text.(string); !ok || s == "" {
+			return fmt.Errorf("选项 %s 内容无效", key)
+		}
+	}
+
+	keys, err := q.AnswerKeys()
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		if _, ok := q.Options[k]; !ok {
+			return fmt.Errorf("答案引用了不存在的选项: %s", k)
+		}
+		if seen[k] {
+			return fmt.Errorf("答案选项重复: %s", k)
+		}
+		seen[k] = true
+	}
+
+	if q.Type == QuestionTypeSingleChoice && len(keys) != 1 {
+		return errors.New("单选题必须有且仅有一个正确选项")
+	}
+	if q.Type == QuestionTypeMultipleChoice && len(keys) < 2 {
+		return errors.New("多选题至少需要两个正确选项")
+	}
+	return nil
+}
+
+func (q *Question) validateFillBlank() error {
+	blanks, err := q.BlankCount()
+	if err != nil {
+		return err
+	}
+	if blanks < 1 {
+		return errors.New("填空题至少需要一个空")
+	}
+	if len(q.Candidates) != blanks {
+		return fmt.Errorf("填空题有 %d 个空，但提供了 %d 组候选答案", blanks, len(q.Candidates))
+	}
+	for i := 1; i <= blanks; i++ {
+		list, err := q.BlankCandidates(i)
+		if err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			return fmt.Errorf("第 %d 个空缺少候选答案", i)
+		}
+	}
+	return nil
+}
+
+// AnswerKeys 返回选择题的正确选项
+func (q *Question) AnswerKeys() ([]string, error) {
+	raw, ok := q.Answers["keys"].([]any)
+	if !ok {
+		return nil, errors.New("选择题答案格式应为 {\"keys\": [...]}")
+	}
+	keys := make([]string, 0, len(raw))
+	for _, v := range raw {
+		s, ok := v.(string)
+		if !ok || s == "" {
+			return nil, errors.New("答案选项必须为非空字符串")
+		}
+		keys = append(keys, s)
+	}
+	return keys, nil
+}
+
+// BlankCount 返回填空题的空数
+func (q *Question) BlankCount() (int, error) {
+	n, ok := q.Answers["blanks"].(float64)
+	if !ok || n != float64(int(n)) {
+		return 0, errors.New("填空题答案格式应为 {\"blanks\": 空数}")
+	}
+	return int(n), nil
+}
+
+// BlankCandidates 返回第 i 个空（从 1 开始）的候选答案
+func (q *Question) BlankCandidates(i int) ([]string, error) {
+	raw, ok := q.Candidates[strconv.Itoa(i)]
+	if !ok {
+		return nil, fmt.Errorf("第 %d 个空缺少候选答案", i)
+	}
+	list, ok := raw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("第 %d 个空的候选答案必须为数组", i)
+	}
+	result := make([]string, 0, len(list))
+	for _, v := range list {
+		s, ok := v.(string)
+		if !ok || s == "" {
+			return nil, fmt.Errorf("第 %d 个空的候选答案必须为非空字符串", i)
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// NormalizeAnswer 按题型校验考生作答并返回统一的 JSON 编码：
+// 选择题为选项数组，填空题为按空位排列的字符串数组，主观题为字符串
+func (q *Question) NormalizeAnswer(raw json.RawMessage) (string, error) {
+	switch q.Type {
+	case QuestionTypeSingleChoice, QuestionTypeMultipleChoice:
+		var keys []string
+		if err := json.Unmarshal(raw, &keys); err != nil {
+			return "", errors.New("选择题作答应为选项数组")
+		}
+		seen := make(map[string]bool, len(keys))
+		for _, k := range keys {
+			if _, ok := q.Options[k]; !ok {
+				return "", fmt.Errorf("选项不存在: %s", k)
+			}
+			if seen[k] {
+				return "", fmt.Errorf("选项重复: %s", k)
+			}
+			seen[k] = true
+		}
+		if q.Type == QuestionTypeSingleChoice && len(keys) > 1 {
+			return "", errors.New("单选题只能选择一个选项")
+		}
+		if keys == nil {
+			keys = []string{}
+		}
+		b, _ := json.Marshal(keys)
+		return string(b), nil
+	case QuestionTypeFillBlank:
+		var blanks []string
+		if err := json.Unmarshal(raw, &blanks); err != nil {
+			return "", errors.New("填空题作答应为字符串数组")
+		}
+		n, err := q.BlankCount()
+		if err != nil {
+			return "", err
+		}
+		if len(blanks) > n {
+			return "", fmt.Errorf("填空题只有 %d 个空", n)
+		}
+		for len(blanks) < n {
+			blanks = append(blanks, "")
+		}
+		b, _ := json.Marshal(blanks)
+		return string(b), nil
+	case QuestionTypeSubjective:
+		var text string
+		if err := json.Unmarshal(raw, &text); err != nil {
+			return "", errors.New("主观题作答应为文本")
+		}
+		b, _ := json.Marshal(text)
+		return string(b), nil
+	default:
+		return "", fmt.Errorf("无效的题目类型: %s", q.Type)
+	}
+}
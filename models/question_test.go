@@ -0,0 +1,35 @@
+package models
+
+import "testing"
+
+func TestQuestionValidate(t *testing.T) {
+	options := JSONB{"A": "北京", "B": "上海", "C": "广州"}
+	cases := []struct {
+		name  string
+		q     Question
+		valid bool
+	}{
+		{"single ok", Question{Type: QuestionTypeSingleChoice, Content: "q", Difficulty: 3, Options: options, Answers: JSONB{"keys": []any{"A"}}}, true},
+		{"single two keys", Question{Type: QuestionTypeSingleChoice, Content: "q", Difficulty: 3, Options: options, Answers: JSONB{"keys": []any{"A", "B"}}}, false},
+		{"single unknown key", Question{Type: QuestionTypeSingleChoice, Content: "q", Difficulty: 3, Options: options, Answers: JSONB{"keys": []any{"D"}}}, false},
+		{"multiple ok", Question{Type: QuestionTypeMultipleChoice, Content: "q", Difficulty: 3, Options: options, Answers: JSONB{"keys": []any{"A", "C"}}}, true},
+		{"multiple one key", Question{Type: QuestionTypeMultipleChoice, Content: "q", Difficulty: 3, Options: options, Answers: JSONB{"keys": []any{"A"}}}, false},
+		{"multiple duplicate", Question{Type: QuestionTypeMultipleChoice, Content: "q", Difficulty: 3, Options: options, Answers: JSONB{"keys": []any{"A", "A"}}}, false},
+		{"blank ok", Question{Type: QuestionTypeFillBlank, Content: "q", Difficulty: 3, Answers: JSONB{"blanks": float64(2)}, Candidates: JSONB{"1": []any{"a"}, "2": []any{"b", "B"}}}, true},
+		{"blank missing", Question{Type: QuestionTypeFillBlank, Content: "q", Difficulty: 3, Answers: JSONB{"blanks": float64(2)}, Candidates: JSONB{"1": []any{"a"}}}, false},
+		{"blank empty list", Question{Type: QuestionTypeFillBlank, Content: "q", Difficulty: 3, Answers: JSONB{"blanks": float64(1)}, Candidates: JSONB{"1": []any{}}}, false},
+		{"subjective ok", Question{Type: QuestionTypeSubjective, Content: "q", Difficulty: 3, Reference: "参考"}, true},
+		{"subjective no reference", Question{Type: QuestionTypeSubjective, Content: "q", Difficulty: 3}, false},
+		{"unknown type", Question{Type: "essay", Content: "q", Difficulty: 3}, false},
+	}
+
+	for _, tc := range cases {
+		err := tc.q.Validate()
+		if tc.valid && err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%s: expected error", tc.name)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UploadSession 分片上传会话。分片按顺序写入暂存文件，Offset 记录已接收的字节数，
+// 客户端中断后可查询 Offset 从断点继续上传。视频信息在创建会话时给出，上传完成后据此创建视频。
+type UploadSession struct {
+	ID          string    `gorm:"type:varchar(32);primaryKey" json:"id"`
+	UserID      uint      `gorm:"index" json:"user_id"`
+	CourseID    uint      `json:"course_id"`
+	UnitID      *uint     `json:"unit_id"`
+	Title       string    `gorm:"type:varchar(100);not null" json:"title"`
+	Description string    `gorm:"type:text" json:"description"`
+	IsMandatory bool      `json:"is_mandatory"`
+	FileName    string    `gorm:"type:varchar(255)" json:"file_name"`
+	Size        int64     `json:"size"`                          // 文件总字节数
+	Offset      int64     `gorm:"column:received" json:"offset"` // 已接收字节数，offset 为 SQL 关键字故换用列名
+	ExpiresAt   time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+var (
+	ErrUploadNotFound       = errors.New("上传会话不存在或已过期")
+	ErrUploadOffsetMismatch = errors.New("分片位置与已上传进度不一致")
+	ErrUploadIncomplete     = errors.New("文件尚未上传完成")
+)
+
+// NewUploadID 生成随机的上传会话ID
+func NewUploadID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// FindUploadSession 查找用户未过期的上传会话
+func FindUploadSession(db *gorm.DB, id string, userID uint) (*UploadSession, error) {
+	var session UploadSession
+	if err := db.Where("id = ? AND user_id = ? AND expires_at > ?", id, userID, time.Now()).
+		First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUploadNotFound
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// AdvanceUploadOffset 将已上传进度从 from 推进到 to 并延长有效期。
+// 进度已被其他请求修改时返回 ErrUploadOffsetMismatch。
+func AdvanceUploadOffset(db *gorm.DB, session *UploadSession, from, to int64, expiresAt time.Time) error {
+	result := db.Model(&UploadSession{}).
+		Where("id = ? AND received = ?", session.ID, from).
+		Updates(map[string]any{"received": to, "expires_at": expiresAt})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUploadOffsetMismatch
+	}
+	session.Offset = to
+	session.ExpiresAt = expiresAt
+	return nil
+}
+
+// ExpiredUploadSessions 返回已过期的上传会话，由调用方清理暂存文件后删除
+func ExpiredUploadSessions(db *gorm.DB, now time.Time) ([]UploadSession, error) {
+	var sessions []UploadSession
+	err := db.Where("expires_at <= ?", now).Find(&sessions).Error
+	return sessions, err
+}
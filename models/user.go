@@ -1,24 +1,33 @@
 package models
 
 import (
+	"errors"
+
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type User struct {
 	gorm.Model
-	Name         string `gorm:"type:varchar(50);not null"`
-	Email        string `gorm:"type:varchar(255);unique;not null; index"`
-	Phone        string `gorm:"type:varchar(20);unique;not null; index"`
-	Age          *uint8 `gorm:"default:null"`
-	Password     string `gorm:"type:varchar(255);not null"`
-	TokenVersion uint   `gorm:"type:int;unsigned;default:0"`
-	Role         string `gorm:"type:varchar(10);not null;default:'user';check:role IN ('user', 'admin')"`
-	CompanyID    uint
-	Company      Company  `gorm:"foreignKey:CompanyID"`
-	Courses      []Course `gorm:"many2many:user_courses;"`
-	Status       bool     `gorm:"default:true"`
+	Name         string  `gorm:"type:varchar(50);not null"`
+	Email        *string `gorm:"type:varchar(255);unique; index"` // 微信登录创建的用户可以没有邮箱
+	Phone        *string `gorm:"type:varchar(20);unique; index"`  // 微信登录创建的用户绑定手机号前为空
+	Age          *uint8  `gorm:"default:null"`
+	Password     string  `gorm:"type:varchar(255);not null"`
+	TokenVersion uint    `gorm:"type:int;unsigned;default:0"`
+	Role         string  `gorm:"type:varchar(10);not null;default:'user';check:role IN ('user', 'admin')"`
+	CompanyID    *uint
+	Company      Company      `gorm:"foreignKey:CompanyID"`
+	Enrollments  []Enrollment `gorm:"foreignKey:UserID"` // 报名的课程
+	Status       bool         `gorm:"default:true"`
+	OpenID       *string      `gorm:"type:varchar(64);uniqueIndex"` // 小程序 openid
+	UnionID      *string      `gorm:"type:varchar(64);index"`       // 微信开放平台 unionid
+	WxSessionKey string       `gorm:"type:varchar(64)" json:"-"`    // 最近一次登录的 session_key，用于解密手机号等数据
 }
 
+// 微信登录自动创建用户时的默认昵称
+const defaultWeChatUserName = "微信用户"
+
 func UserAutoMigrate(db *gorm.DB) {
 	db.AutoMigrate(&User{})
 }
@@ -42,7 +51,7 @@ func FindUSerByEmail(db *gorm.DB, email string) *User {
 
 func FindUserByID(db *gorm.DB, id uint) *User {
 	var user User
-	db.Select("id", "name", "created_at").Where("id = ?", id).First(&user)
+	db.Select("id", "name", "role", "status", "token_version", "created_at").Where("id = ?", id).First(&user)
 	return &user
 }
 
@@ -66,3 +75,53 @@ func CreateUserIfNotExists(db *gorm.DB, userInput *User) (*User, bool, error) {
 	// 用户已存在
 	return &existingUser, false, nil
 }
+
+// WeChatLogin 按 openid 查找微信登录的用户并更新 session_key。
+// openid 未绑定时按 unionid 查找同一主体下其他应用登录过的用户并绑定 openid；都找不到时创建新用户。
+// 新用户处于待审核状态，管理员审核通过或绑定手机号合并到预先导入的用户后才能使用。
+// created 表示本次登录新建了用户。
+func WeChatLogin(db *gorm.DB, openID, unionID, sessionKey string) (user *User, created bool, err error) {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var u User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("open_id = ?", openID).First(&u).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) && unionID != "" {
+			err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("union_id = ? AND open_id IS NULL", unionID).First(&u).Error
+		}
+
+		switch {
+		case err == nil:
+			updates := map[string]any{"open_id": openID, "wx_session_key": sessionKey}
+			if unionID != "" {
+				updates["union_id"] = unionID
+			}
+			if err := tx.Model(&u).Updates(updates).Error; err != nil {
+				return err
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			u = User{
+				Name:         defaultWeChatUserName,
+				Role:         "user",
+				OpenID:       &openID,
+				WxSessionKey: sessionKey,
+			}
+			if unionID != "" {
+				u.UnionID = &unionID
+			}
+			if err := tx.Create(&u).Error; err != nil {
+				return err
+			}
+			// Status 的默认值为 true，创建时零值会被忽略，需要单独写入
+			if err := tx.Model(&u).UpdateColumn("status", false).Error; err != nil {
+				return err
+			}
+			u.Status = false
+			created = true
+		default:
+			return err
+		}
+		user = &u
+		return nil
+	})
+	return user, created, err
+}
@@ -0,0 +1,167 @@
+package models
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrPhoneBound = errors.New("该手机号已绑定其他微信账号")
+	ErrPhoneTaken = errors.New("该手机号已被其他账号使用")
+)
+
+// BindPhone 为用户绑定已通过微信验证的手机号。
+// 手机号属于管理员预先导入、尚未绑定微信的用户时，将当前微信用户合并到该用户：
+// 微信身份、报名记录与考试记录转移过去后删除当前用户，返回的 user 为合并后保留的用户，merged 为 true。
+// 只有尚未绑定手机号的用户（即微信登录自动创建的用户）才会被合并。
+func BindPhone(db *gorm.DB, userID uint, phone string) (user *User, merged bool, err error) {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var u User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&u, userID).Error; err != nil {
+			return err
+		}
+		if u.Phone != nil && *u.Phone == phone {
+			user = &u
+			return nil
+		}
+
+		var owner User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("phone = ?", phone).First(&owner).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := tx.Model(&u).Update("phone", phone).Error; err != nil {
+				return err
+			}
+			user = &u
+			return nil
+		case err != nil:
+			return err
+		case owner.OpenID != nil:
+			return ErrPhoneBound
+		case u.Phone != nil:
+			return ErrPhoneTaken
+		}
+
+		if err := mergeUser(tx, &u, &owner); err != nil {
+			return err
+		}
+		if err := tx.First(&owner, owner.ID).Error; err != nil {
+			return err
+		}
+		user, merged = &owner, true
+		return nil
+	})
+	return user, merged, err
+}
+
+// mergeUser 将微信登录创建的用户 from 合并到预先导入的用户 to 后删除 from。
+// 两人报名了同一课程时按 mergeEnrollment 合并两条报名记录，学习进度不会丢失。
+func mergeUser(tx *gorm.DB, from, to *User) error {
+	updates := map[string]any{"open_id": from.OpenID, "wx_session_key": from.WxSessionKey}
+	if from.UnionID != nil {
+		updates["union_id"] = from.UnionID
+	}
+	// openid 有唯一索引，先从原用户上清除再转移
+	if err := tx.Model(&User{}).Where("id = ?", from.ID).
+		Updates(map[string]any{"open_id": nil, "union_id": nil}).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&User{}).Where("id = ?", to.ID).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	var enrollments []Enrollment
+	if err := tx.Where("user_id = ?", from.ID).Find(&enrollments).Error; err != nil {
+		return err
+	}
+	var mergedCourses []uint
+	for _, e := range enrollments {
+		existing, err := FindEnrollment(tx, to.ID, e.CourseID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if err := tx.Model(&e).Update("user_id", to.ID).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := mergeEnrollment(tx, &e, existing, to.ID); err != nil {
+			return err
+		}
+		mergedCourses = append(mergedCourses, e.CourseID)
+	}
+
+	if err := tx.Model(&ExamAttempt{}).Where("user_id = ?", from.ID).Update("user_id", to.ID).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&UploadSession{}).Where("user_id = ?", from.ID).Update("user_id", to.ID).Error; err != nil {
+		return err
+	}
+
+	// 合并观看进度与考试记录后可能已满足完成条件
+	for _, courseID := range mergedCourses {
+		if _, err := NewCourseService(tx).evaluateCompletion(to.ID, courseID); err != nil {
+			return err
+		}
+	}
+	return tx.Delete(from).Error
+}
+
+// mergeEnrollment 合并同一课程的两条报名记录：保留学习进度更多的一条并归属 userID，
+// 另一条的视频观看进度并入保留的记录后删除。同一视频都有观看进度时保留看得更远的。
+// 考试记录按用户保存，由 mergeUser 统一转移。
+func mergeEnrollment(tx *gorm.DB, a, b *Enrollment, userID uint) error {
+	keep, drop := b, a
+	// 已完成的记录优先，其次是进度更高的
+	if a.IsCompleted && !b.IsCompleted || a.IsCompleted == b.IsCompleted && a.Progress > b.Progress {
+		keep, drop = a, b
+	}
+	if err := tx.Model(&Enrollment{}).Where("id = ?", keep.ID).Update("user_id", userID).Error; err != nil {
+		return err
+	}
+
+	var rows []UserVideoProgress
+	if err := tx.Where("enrollment_id = ?", drop.ID).Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, p := range rows {
+		var kept UserVideoProgress
+		err := tx.Where("enrollment_id = ? AND video_id = ?", keep.ID, p.VideoID).First(&kept).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if err := tx.Model(&p).Update("enrollment_id", keep.ID).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		updates := map[string]any{}
+		if p.Position > kept.Position {
+			updates["position"] = p.Position
+			updates["progress"] = p.Progress
+			updates["session_id"] = p.SessionID
+			updates["session_position"] = p.SessionPosition
+			updates["last_watched"] = p.LastWatched
+		}
+		if p.IsCompleted && !kept.IsCompleted {
+			updates["is_completed"] = true
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(&kept).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Delete(&p).Error; err != nil {
+			return err
+		}
+	}
+	if err := releaseEnrollment(tx, drop); err != nil {
+		return err
+	}
+	return tx.Delete(drop).Error
+}
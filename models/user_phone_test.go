@@ -0,0 +1,71 @@
+package models
+
+import "testing"
+
+func TestBindPhoneMergeKeepsProgress(t *testing.T) {
+	db := openTestDB(t)
+	course := Course{Name: "安全生产", EnrollmentCode: "c1", EnrollmentCount: 2}
+	other := Course{Name: "消防安全", EnrollmentCode: "c2", EnrollmentCount: 1}
+	db.Create(&course)
+	db.Create(&other)
+	v1 := Video{CourseID: course.ID, Title: "第一课", URL: "1.mp4", Duration: 600, IsMandatory: true}
+	v2 := Video{CourseID: course.ID, Title: "第二课", URL: "2.mp4", Duration: 600, IsMandatory: true}
+	db.Create(&v1)
+	db.Create(&v2)
+
+	phone, openID := "13800000001", "openid-1"
+	imported := User{Name: "张三", Phone: &phone, Role: "user"}
+	wx := User{Name: defaultWeChatUserName, Role: "user", OpenID: &openID, WxSessionKey: "key"}
+	db.Create(&imported)
+	db.Create(&wx)
+
+	// 预导入的用户看完了第二课，第一课看了一部分
+	kept := Enrollment{UserID: imported.ID, CourseID: course.ID, Progress: 50}
+	db.Create(&kept)
+	db.Create(&UserVideoProgress{EnrollmentID: kept.ID, VideoID: v1.ID, Position: 100, Progress: 16})
+	db.Create(&UserVideoProgress{EnrollmentID: kept.ID, VideoID: v2.ID, Position: 600, Progress: 100, IsCompleted: true})
+
+	// 微信用户在同一课程看完了第一课，另外报名了一门课程
+	dup := Enrollment{UserID: wx.ID, CourseID: course.ID, Progress: 50}
+	db.Create(&dup)
+	db.Create(&UserVideoProgress{EnrollmentID: dup.ID, VideoID: v1.ID, Position: 600, Progress: 100, IsCompleted: true})
+	db.Create(&Enrollment{UserID: wx.ID, CourseID: other.ID, Progress: 30})
+
+	user, merged, err := BindPhone(db, wx.ID, phone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !merged || user.ID != imported.ID {
+		t.Fatalf("merged=%v user=%d，应合并到预导入的用户 %d", merged, user.ID, imported.ID)
+	}
+	if user.OpenID == nil || *user.OpenID != openID {
+		t.Fatalf("openid 未转移: %v", user.OpenID)
+	}
+	if err := db.First(&User{}, wx.ID).Error; err == nil {
+		t.Fatal("合并后微信用户未删除")
+	}
+
+	var enrollments []Enrollment
+	db.Where("user_id = ?", imported.ID).Order("course_id").Find(&enrollments)
+	if len(enrollments) != 2 {
+		t.Fatalf("合并后报名记录数 = %d", len(enrollments))
+	}
+	e := enrollments[0]
+	if !e.IsCompleted || e.Progress != 100 {
+		t.Fatalf("两人的观看进度合并后应完成课程: progress=%d completed=%v", e.Progress, e.IsCompleted)
+	}
+	if enrollments[1].CourseID != other.ID || enrollments[1].Progress != 30 {
+		t.Fatalf("另一门课程的报名记录未转移: %+v", enrollments[1])
+	}
+
+	var rows []UserVideoProgress
+	db.Where("enrollment_id = ?", e.ID).Order("video_id").Find(&rows)
+	if len(rows) != 2 || !rows[0].IsCompleted || rows[0].Position != 600 || !rows[1].IsCompleted {
+		t.Fatalf("观看进度未合并: %+v", rows)
+	}
+
+	db.First(&course, course.ID)
+	if course.EnrollmentCount != 1 || course.CompletionCount != 1 {
+		t.Fatalf("课程人数: enrollment=%d completion=%d", course.EnrollmentCount, course.CompletionCount)
+	}
+}
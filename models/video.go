@@ -1,6 +1,9 @@
 package models
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -9,12 +12,102 @@ import (
 // 视频信息表
 type Video struct {
 	gorm.Model
-	CourseID     uint      `gorm:"index"` // 外键
-	UnitID       *uint     // 所属单元
-	Title        string    `gorm:"type:varchar(100);not null"` // 视频标题
-	URL          string    `gorm:"type:varchar(255);not null"` // 视频地址
-	Duration     int       `gorm:"default:0"`                  // 时长（秒）
-	IsMandatory  bool      `gorm:"default:true"`               // 是否必修
-	WatchedCount uint      `gorm:"default:0"`                  // 观看次数
-	LastWatched  time.Time // 最后观看时间
+	CourseID     uint            `gorm:"index"` // 外键
+	Course       *Course         `gorm:"foreignKey:CourseID" json:",omitempty"`
+	UnitID       *uint           // 所属单元
+	Unit         *CourseUnit     `gorm:"foreignKey:UnitID" json:",omitempty"`
+	Title        string          `gorm:"type:varchar(100);not null"` // 视频标题
+	Description  string          `gorm:"type:text"`                  // 视频简介
+	URL          string          `gorm:"type:varchar(255);not null"` // 视频地址
+	StorageKey   string          `gorm:"type:varchar(255)"`          // 上传文件在存储后端中的对象键，外部地址时为空
+	Size         int64           `gorm:"default:0"`                  // 文件大小（字节）
+	MimeType     string          `gorm:"type:varchar(50)"`           // 文件类型
+	Duration     int             `gorm:"default:0"`                  // 时长（秒）
+	Width        int             `gorm:"default:0"`                  // 画面宽度（像素）
+	Height       int             `gorm:"default:0"`                  // 画面高度（像素）
+	VideoCodec   string          `gorm:"type:varchar(50)"`           // 视频编码
+	AudioCodec   string          `gorm:"type:varchar(50)"`           // 音频编码
+	FastStart    *bool           // 文件信息位于开头可边下边播，非 MP4 或外部地址时为空
+	Subtitles    []VideoSubtitle `gorm:"foreignKey:VideoID"`
+	Chapters     []VideoChapter  `gorm:"foreignKey:VideoID"`
+	IsMandatory  bool            `gorm:"default:true"` // 是否必修
+	WatchedCount uint            `gorm:"default:0"`    // 观看次数
+	LastWatched  time.Time       // 最后观看时间
+}
+
+var (
+	ErrVideoTitleExists = errors.New("同课程下视频标题不能重复")
+	ErrInvalidVideo     = errors.New("视频信息无效")
+)
+
+// ValidateVideo 校验视频所属课程与单元，以及同课程下标题不重复。
+// 上传文件前调用，避免文件写入存储后才发现信息有误。
+func (s *CourseService) ValidateVideo(video *Video) error {
+	video.Title = strings.TrimSpace(video.Title)
+	if video.Title == "" {
+		return fmt.Errorf("%w: 视频标题不能为空", ErrInvalidVideo)
+	}
+	if video.Duration < 0 {
+		return fmt.Errorf("%w: 视频时长不能为负数", ErrInvalidVideo)
+	}
+
+	if !recordExists(s.db, &Course{}, video.CourseID) {
+		return ErrCourseNotFound
+	}
+	if video.UnitID != nil {
+		if err := checkUnitInCourse(s.db, *video.UnitID, video.CourseID); err != nil {
+			return err
+		}
+	}
+
+	var count int64
+	if err := s.db.Model(&Video{}).
+		Where("course_id = ? AND title = ? AND id <> ?", video.CourseID, video.Title, video.ID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrVideoTitleExists
+	}
+	return nil
+}
+
+// CreateVideo 创建视频
+func (s *CourseService) CreateVideo(video *Video) error {
+	if strings.TrimSpace(video.URL) == "" {
+		return fmt.Errorf("%w: 视频地址不能为空", ErrInvalidVideo)
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := NewCourseService(tx).ValidateVideo(video); err != nil {
+			return err
+		}
+		// is_mandatory 默认为 true，Create 会忽略零值并回填默认值，选修视频需单独写入
+		mandatory := video.IsMandatory
+		if err := tx.Omit("Course", "Unit").Create(video).Error; err != nil {
+			return err
+		}
+		if !mandatory {
+			video.IsMandatory = false
+			return tx.Model(video).UpdateColumn("is_mandatory", false).Error
+		}
+		return nil
+	})
+}
+
+// FindPlayableVideo 查找学员可观看的视频，未报名视频所属课程时返回 ErrNotEnrolled
+func FindPlayableVideo(db *gorm.DB, userID, videoID uint) (*Video, error) {
+	var video Video
+	if err := db.First(&video, videoID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrVideoNotFound
+		}
+		return nil, err
+	}
+	if _, err := FindEnrollment(db, userID, video.CourseID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotEnrolled
+		}
+		return nil, err
+	}
+	return &video, nil
 }
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// VideoChapter 视频章节标记，学员可据此跳转到指定位置
+type VideoChapter struct {
+	gorm.Model
+	VideoID uint    `gorm:"index"`
+	Start   float64 `gorm:"not null"`                   // 起始位置（秒）
+	Title   string  `gorm:"type:varchar(100);not null"` // 章节标题
+}
+
+var ErrInvalidChapter = errors.New("章节信息无效")
+
+// OrderChapters 按起始位置排序章节，用于 Preload
+func OrderChapters(db *gorm.DB) *gorm.DB {
+	return db.Order("start ASC")
+}
+
+// SetVideoChapters 以给出的章节替换视频的全部章节，章节按起始位置排序。
+// 起始位置不能为负、不能重复，已知视频时长时不能超出时长。
+func (s *CourseService) SetVideoChapters(videoID uint, chapters []VideoChapter) ([]VideoChapter, error) {
+	seen := make(map[float64]bool, len(chapters))
+	for i := range chapters {
+		c := &chapters[i]
+		c.ID = 0
+		c.VideoID = videoID
+		c.Title = strings.TrimSpace(c.Title)
+		if c.Title == "" {
+			return nil, fmt.Errorf("%w: 章节标题不能为空", ErrInvalidChapter)
+		}
+		if c.Start < 0 || math.IsNaN(c.Start) || math.IsInf(c.Start, 0) {
+			return nil, fmt.Errorf("%w: 章节起始位置无效", ErrInvalidChapter)
+		}
+		if seen[c.Start] {
+			return nil, fmt.Errorf("%w: 章节起始位置重复", ErrInvalidChapter)
+		}
+		seen[c.Start] = true
+	}
+	sort.Slice(chapters, func(i, j int) bool { return chapters[i].Start < chapters[j].Start })
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var video Video
+		if err := tx.Select("id", "duration").First(&video, videoID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrVideoNotFound
+			}
+			return err
+		}
+		if n := len(chapters); n > 0 && video.Duration > 0 && chapters[n-1].Start >= float64(video.Duration) {
+			return fmt.Errorf("%w: 章节起始位置超出视频时长", ErrInvalidChapter)
+		}
+
+		if err := tx.Where("video_id = ?", videoID).Delete(&VideoChapter{}).Error; err != nil {
+			return err
+		}
+		if len(chapters) == 0 {
+			return nil
+		}
+		return tx.Create(&chapters).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return chapters, nil
+}
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrVideoNotFound       = errors.New("视频不存在")
+	ErrNotEnrolled         = errors.New("未报名该课程")
+	ErrImplausibleProgress = errors.New("观看进度异常")
+)
+
+// HeartbeatPolicy 观看心跳的校验规则
+type HeartbeatPolicy struct {
+	CompleteThreshold float64 // 看完阈值（百分比）
+	MaxPlaybackRate   float64 // 允许的最大播放倍速
+	JumpTolerance     float64 // 容差（秒）
+}
+
+// Heartbeat 播放器上报的观看心跳
+type Heartbeat struct {
+	SessionID string  // 播放会话ID，每次打开播放器生成
+	Position  float64 // 当前播放位置（秒）
+}
+
+// RecordHeartbeat 根据心跳更新观看进度。进度只增不减；
+// 每个会话的首个心跳会增加视频观看次数，同一会话ID只计一次；已看范围按墙钟时间与最大倍速推进，超出可达范围的心跳被拒绝。
+// 必修视频看完时重新评估课程完成情况。
+func RecordHeartbeat(db *gorm.DB, userID, videoID uint, hb Heartbeat, policy HeartbeatPolicy) (*UserVideoProgress, error) {
+	if hb.Position < 0 || math.IsNaN(hb.Position) || math.IsInf(hb.Position, 0) {
+		return nil, fmt.Errorf("%w: 无效的播放位置", ErrImplausibleProgress)
+	}
+
+	var video Video
+	if err := db.First(&video, videoID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrVideoNotFound
+		}
+		return nil, err
+	}
+	if video.Duration > 0 && hb.Position > float64(video.Duration)+policy.JumpTolerance {
+		return nil, fmt.Errorf("%w: 播放位置超出视频时长", ErrImplausibleProgress)
+	}
+
+	var enrollment Enrollment
+	if err := db.Where("user_id = ? AND course_id = ?", userID, video.CourseID).
+		First(&enrollment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotEnrolled
+		}
+		return nil, err
+	}
+
+	var progress UserVideoProgress
+	now := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("enrollment_id = ? AND video_id = ?", enrollment.ID, videoID).
+			First(&progress).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			progress = UserVideoProgress{EnrollmentID: enrollment.ID, VideoID: videoID}
+		} else if err != nil {
+			return err
+		}
+
+		newSession := progress.SessionID != hb.SessionID
+		if hb.Position > progress.Position {
+			// 已看范围只能随墙钟时间推进：本会话向前连续播放时，从上次心跳的位置（不超过已看范围）起
+			// 最多前进 经过时间×最大倍速；新会话或拖动不推进已看范围。容差只用于吸收心跳抖动，
+			// 不计入已看范围，连续的小步心跳或轮换会话都无法累积容差
+			reach := progress.Position
+			if !newSession && hb.Position >= progress.SessionPosition {
+				elapsed := math.Max(now.Sub(progress.LastWatched).Seconds(), 0)
+				start := math.Min(progress.SessionPosition, progress.Position)
+				reach = math.Max(reach, start+elapsed*policy.MaxPlaybackRate)
+			}
+			if hb.Position > reach+policy.JumpTolerance {
+				if newSession {
+					return fmt.Errorf("%w: 不能从未观看的位置开始播放", ErrImplausibleProgress)
+				}
+				return fmt.Errorf("%w: 播放位置 %.0f 秒超出可达范围 %.0f 秒", ErrImplausibleProgress,
+					hb.Position, reach)
+			}
+			progress.Position = math.Min(hb.Position, reach)
+		}
+
+		progress.SessionID = hb.SessionID
+		progress.SessionPosition = hb.Position
+		progress.LastWatched = now
+		if video.Duration > 0 {
+			percent := math.Min(progress.Position/float64(video.Duration)*100, 100)
+			if percent > progress.Progress {
+				progress.Progress = percent
+			}
+		}
+		justCompleted := false
+		if !progress.IsCompleted && video.Duration > 0 && progress.Progress >= policy.CompleteThreshold {
+			progress.IsCompleted = true
+			justCompleted = true
+		}
+
+		if err := tx.Omit(clause.Associations).Save(&progress).Error; err != nil {
+			return err
+		}
+
+		// 会话ID首次出现时才计入观看次数
+		counted := false
+		if newSession {
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&VideoWatchSession{
+				EnrollmentID: enrollment.ID,
+				VideoID:      videoID,
+				SessionID:    hb.SessionID,
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+			counted = result.RowsAffected > 0
+		}
+		if counted {
+			if err := tx.Model(&Video{}).Where("id = ?", videoID).Updates(map[string]any{
+				"watched_count": gorm.Expr("watched_count + 1"),
+				"last_watched":  now,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		if justCompleted && video.IsMandatory {
+			if _, err := NewCourseService(tx).EvaluateCompletion(userID, video.CourseID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &progress, nil
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB 创建内存数据库并建表，每个测试使用独立的数据库
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := AutoMigrate(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestRecordHeartbeatBoundByWallClock(t *testing.T) {
+	db := openTestDB(t)
+	course := Course{Name: "安全生产", EnrollmentCode: "c1"}
+	db.Create(&course)
+	video := Video{CourseID: course.ID, Title: "第一课", URL: "v.mp4", Duration: 3600, IsMandatory: true}
+	db.Create(&video)
+	db.Create(&Enrollment{UserID: 1, CourseID: course.ID})
+	policy := HeartbeatPolicy{CompleteThreshold: 95, MaxPlaybackRate: 2, JumpTolerance: 5}
+
+	beat := func(session string, pos float64) (*UserVideoProgress, error) {
+		return RecordHeartbeat(db, 1, video.ID, Heartbeat{SessionID: session, Position: pos}, policy)
+	}
+	// 将最近一次心跳提前，模拟经过的墙钟时间
+	wait := func(d time.Duration) {
+		db.Model(&UserVideoProgress{}).Where("video_id = ?", video.ID).
+			UpdateColumn("last_watched", gorm.Expr("?", time.Now().Add(-d)))
+	}
+
+	if _, err := beat("s1", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// 以容差为步长快速上报
+	rejected := 0
+	for pos := policy.JumpTolerance; pos <= 3600; pos += policy.JumpTolerance {
+		if _, err := beat("s1", pos); errors.Is(err, ErrImplausibleProgress) {
+			rejected++
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if rejected == 0 {
+		t.Fatal("快速的小步心跳未被拒绝")
+	}
+
+	// 每次更换会话
+	for i, pos := 0, policy.JumpTolerance; pos <= 3600; i, pos = i+1, pos+policy.JumpTolerance {
+		if _, err := beat(fmt.Sprint("rotate-", i), pos); err != nil && !errors.Is(err, ErrImplausibleProgress) {
+			t.Fatal(err)
+		}
+	}
+
+	var progress UserVideoProgress
+	db.Where("video_id = ?", video.ID).First(&progress)
+	if progress.Position > 1 || progress.IsCompleted {
+		t.Fatalf("快速心跳推进了进度: position=%.2f completed=%v", progress.Position, progress.IsCompleted)
+	}
+
+	// 正常播放：10 秒墙钟时间前进 10 秒
+	if _, err := beat("s2", progress.Position); err != nil {
+		t.Fatal(err)
+	}
+	wait(10 * time.Second)
+	p, err := beat("s2", progress.Position+10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Position < progress.Position+9.9 {
+		t.Fatalf("正常播放未推进进度: %.2f", p.Position)
+	}
+
+	// 倍速上限内的播放可以推进，超出上限则拒绝
+	wait(10 * time.Second)
+	if _, err := beat("s2", p.Position+20); err != nil {
+		t.Fatal(err)
+	}
+	wait(10 * time.Second)
+	if _, err := beat("s2", p.Position+20+60); !errors.Is(err, ErrImplausibleProgress) {
+		t.Fatalf("超出最大倍速 err = %v", err)
+	}
+}
+
+func TestRecordHeartbeatCountsSessionOnce(t *testing.T) {
+	db := openTestDB(t)
+	course := Course{Name: "安全生产", EnrollmentCode: "c1"}
+	db.Create(&course)
+	video := Video{CourseID: course.ID, Title: "第一课", URL: "v.mp4", Duration: 3600}
+	db.Create(&video)
+	db.Create(&Enrollment{UserID: 1, CourseID: course.ID})
+	policy := HeartbeatPolicy{CompleteThreshold: 95, MaxPlaybackRate: 2, JumpTolerance: 5}
+
+	// 两个播放器交替上报
+	for i := 0; i < 10; i++ {
+		session := []string{"a", "b"}[i%2]
+		if _, err := RecordHeartbeat(db, 1, video.ID, Heartbeat{SessionID: session}, policy); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db.First(&video, video.ID)
+	if video.WatchedCount != 2 {
+		t.Fatalf("watched_count = %d，每个会话应只计一次", video.WatchedCount)
+	}
+}
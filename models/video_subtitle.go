@@ -0,0 +1,74 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// VideoSubtitle 视频字幕轨道，文件统一保存为 WebVTT
+type VideoSubtitle struct {
+	gorm.Model
+	VideoID    uint   `gorm:"index"`
+	Language   string `gorm:"type:varchar(20);not null"` // 语言代码，如 zh-CN、en
+	Label      string `gorm:"type:varchar(50)"`          // 播放器中显示的名称，如 中文
+	StorageKey string `gorm:"type:varchar(255)"`
+	URL        string `gorm:"type:varchar(255);not null"`
+}
+
+var (
+	ErrSubtitleNotFound = errors.New("字幕不存在")
+	ErrInvalidSubtitle  = errors.New("字幕信息无效")
+)
+
+// SetVideoSubtitle 为视频添加字幕，同一语言已有字幕时替换，返回被替换的字幕以便调用方删除其文件
+func (s *CourseService) SetVideoSubtitle(subtitle *VideoSubtitle) (*VideoSubtitle, error) {
+	subtitle.Language = strings.TrimSpace(subtitle.Language)
+	if subtitle.Language == "" {
+		return nil, fmt.Errorf("%w: 语言不能为空", ErrInvalidSubtitle)
+	}
+	if subtitle.Label = strings.TrimSpace(subtitle.Label); subtitle.Label == "" {
+		subtitle.Label = subtitle.Language
+	}
+
+	var replaced *VideoSubtitle
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if !recordExists(tx, &Video{}, subtitle.VideoID) {
+			return ErrVideoNotFound
+		}
+
+		var old VideoSubtitle
+		err := tx.Where("video_id = ? AND language = ?", subtitle.VideoID, subtitle.Language).First(&old).Error
+		switch {
+		case err == nil:
+			if err := tx.Delete(&old).Error; err != nil {
+				return err
+			}
+			replaced = &old
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+		return tx.Create(subtitle).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return replaced, nil
+}
+
+// DeleteVideoSubtitle 删除字幕，返回被删除的字幕以便调用方删除其文件
+func (s *CourseService) DeleteVideoSubtitle(subtitleID uint) (*VideoSubtitle, error) {
+	var subtitle VideoSubtitle
+	if err := s.db.First(&subtitle, subtitleID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSubtitleNotFound
+		}
+		return nil, err
+	}
+	if err := s.db.Delete(&subtitle).Error; err != nil {
+		return nil, err
+	}
+	return &subtitle, nil
+}
@@ -0,0 +1,303 @@
+// Package mp4 解析 MP4（ISO BMFF，含 QuickTime MOV）容器的基本信息：时长、画面尺寸、
+// 音视频编码，以及 moov 是否位于 mdat 之前（fast-start）。
+// 解析按顺序读取，只把 moov 载入内存，其余 box 直接跳过，可用于边上传边解析。
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Info MP4 文件信息
+type Info struct {
+	Duration   float64 // 时长（秒）
+	Width      int     // 画面宽度（像素），无视频轨时为 0
+	Height     int     // 画面高度（像素）
+	VideoCodec string  // 视频编码，如 avc1.64001f、hvc1
+	AudioCodec string  // 音频编码，如 mp4a
+	// FastStart moov 位于 mdat 之前，播放器无需下载整个文件即可开始播放
+	FastStart bool
+}
+
+var (
+	ErrNotMP4 = errors.New("不是有效的 MP4 文件")
+	ErrNoMoov = errors.New("MP4 文件缺少 moov 信息")
+)
+
+// maxMoovSize moov 的大小上限，防止异常文件占用过多内存
+const maxMoovSize = 64 << 20
+
+// Parse 顺序读取 MP4 文件并解析 moov。读到 moov 后即返回，不会读完整个文件。
+func Parse(r io.Reader) (*Info, error) {
+	mdatSeen := false
+	for {
+		size, typ, headerLen, err := readBoxHeader(r)
+		if err == io.EOF {
+			return nil, ErrNoMoov
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch typ {
+		case "moov":
+			if size == 0 || size-headerLen > maxMoovSize {
+				return nil, fmt.Errorf("%w: moov 过大", ErrNotMP4)
+			}
+			body := make([]byte, size-headerLen)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return nil, fmt.Errorf("%w: moov 不完整", ErrNotMP4)
+			}
+			info, err := parseMoov(body)
+			if err != nil {
+				return nil, err
+			}
+			info.FastStart = !mdatSeen
+			return info, nil
+		case "mdat":
+			mdatSeen = true
+		}
+
+		// size 为 0 表示该 box 延续到文件末尾
+		if size == 0 {
+			return nil, ErrNoMoov
+		}
+		if _, err := io.CopyN(io.Discard, r, int64(size-headerLen)); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, ErrNoMoov
+			}
+			return nil, err
+		}
+	}
+}
+
+// readBoxHeader 读取 box 头，返回 box 总长度（含头）、类型与头长度
+func readBoxHeader(r io.Reader) (size uint64, typ string, headerLen uint64, err error) {
+	var hdr [8]byte
+	if _, err = io.ReadFull(r, hdr[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			err = fmt.Errorf("%w: box 头不完整", ErrNotMP4)
+		}
+		return
+	}
+	size = uint64(binary.BigEndian.Uint32(hdr[:4]))
+	typ = string(hdr[4:8])
+	headerLen = 8
+	if !validType(typ) {
+		err = ErrNotMP4
+		return
+	}
+
+	if size == 1 {
+		var large [8]byte
+		if _, err = io.ReadFull(r, large[:]); err != nil {
+			err = fmt.Errorf("%w: box 头不完整", ErrNotMP4)
+			return
+		}
+		size = binary.BigEndian.Uint64(large[:])
+		headerLen = 16
+	}
+	if size != 0 && size < headerLen {
+		err = fmt.Errorf("%w: box 长度无效", ErrNotMP4)
+	}
+	return
+}
+
+// validType box 类型应为 4 个可打印字符
+func validType(typ string) bool {
+	for i := 0; i < len(typ); i++ {
+		if typ[i] < 0x20 || typ[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// box 已载入内存的 box
+type box struct {
+	typ  string
+	data []byte // 不含头
+}
+
+// children 拆分内存中的连续 box
+func children(data []byte) ([]box, error) {
+	var boxes []box
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, fmt.Errorf("%w: box 头不完整", ErrNotMP4)
+		}
+		size := uint64(binary.BigEndian.Uint32(data[:4]))
+		typ := string(data[4:8])
+		headerLen := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return nil, fmt.Errorf("%w: box 头不完整", ErrNotMP4)
+			}
+			size = binary.BigEndian.Uint64(data[8:16])
+			headerLen = 16
+		}
+		if size < headerLen || size > uint64(len(data)) {
+			return nil, fmt.Errorf("%w: box 长度无效", ErrNotMP4)
+		}
+		boxes = append(boxes, box{typ: typ, data: data[headerLen:size]})
+		data = data[size:]
+	}
+	return boxes, nil
+}
+
+// find 按路径查找第一个匹配的子 box
+func find(data []byte, path ...string) ([]byte, bool) {
+	for _, typ := range path {
+		boxes, err := children(data)
+		if err != nil {
+			return nil, false
+		}
+		found := false
+		for _, b := range boxes {
+			if b.typ == typ {
+				data, found = b.data, true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return data, true
+}
+
+func parseMoov(moov []byte) (*Info, error) {
+	boxes, err := children(moov)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &Info{}
+	var trackDuration float64
+	for _, b := range boxes {
+		switch b.typ {
+		case "mvhd":
+			timescale, duration, ok := parseTimes(b.data, 12, 20)
+			if !ok {
+				return nil, fmt.Errorf("%w: mvhd 无效", ErrNotMP4)
+			}
+			if timescale > 0 {
+				info.Duration = float64(duration) / float64(timescale)
+			}
+		case "trak":
+			d := parseTrak(b.data, info)
+			if d > trackDuration {
+				trackDuration = d
+			}
+		}
+	}
+	// 分片 MP4 的 mvhd 时长可能为 0，退而使用最长的轨道时长
+	if info.Duration == 0 {
+		info.Duration = trackDuration
+	}
+	return info, nil
+}
+
+// parseTimes 读取 mvhd/mdhd 中的 timescale 与 duration。
+// version 0 时字段为 32 位，v0Offset 为 timescale 的偏移；version 1 时时间字段为 64 位。
+func parseTimes(data []byte, v0Offset, v1Offset int) (timescale uint32, duration uint64, ok bool) {
+	if len(data) < 4 {
+		return 0, 0, false
+	}
+	if data[0] == 1 {
+		if len(data) < v1Offset+12 {
+			return 0, 0, false
+		}
+		return binary.BigEndian.Uint32(data[v1Offset:]), binary.BigEndian.Uint64(data[v1Offset+4:]), true
+	}
+	if len(data) < v0Offset+8 {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint32(data[v0Offset:]), uint64(binary.BigEndian.Uint32(data[v0Offset+4:])), true
+}
+
+// parseTrak 解析轨道的类型、编码与画面尺寸，返回轨道时长（秒）
+func parseTrak(trak []byte, info *Info) float64 {
+	var duration float64
+	if mdhd, ok := find(trak, "mdia", "mdhd"); ok {
+		if timescale, d, ok := parseTimes(mdhd, 12, 20); ok && timescale > 0 {
+			duration = float64(d) / float64(timescale)
+		}
+	}
+
+	hdlr, ok := find(trak, "mdia", "hdlr")
+	if !ok || len(hdlr) < 12 {
+		return duration
+	}
+	entry, ok := sampleEntry(trak)
+	if !ok {
+		return duration
+	}
+
+	switch string(hdlr[8:12]) {
+	case "vide":
+		if info.VideoCodec != "" {
+			return duration
+		}
+		info.VideoCodec = videoCodec(entry)
+		info.Width, info.Height = trackSize(trak, entry)
+	case "soun":
+		if info.AudioCodec == "" {
+			info.AudioCodec = entry.typ
+		}
+	}
+	return duration
+}
+
+// sampleEntry 返回 stsd 中的第一个样本描述
+func sampleEntry(trak []byte) (box, bool) {
+	stsd, ok := find(trak, "mdia", "minf", "stbl", "stsd")
+	if !ok || len(stsd) < 8 {
+		return box{}, false
+	}
+	entries, err := children(stsd[8:])
+	if err != nil || len(entries) == 0 {
+		return box{}, false
+	}
+	return entries[0], true
+}
+
+// visualSampleEntryLen VisualSampleEntry 固定字段的长度，其后为 avcC 等子 box
+const visualSampleEntryLen = 78
+
+// videoCodec 返回视频编码；H.264 附带 avcC 中的 profile 与 level，如 avc1.64001f
+func videoCodec(entry box) string {
+	if (entry.typ == "avc1" || entry.typ == "avc3") && len(entry.data) > visualSampleEntryLen {
+		if avcC, ok := find(entry.data[visualSampleEntryLen:], "avcC"); ok && len(avcC) >= 4 {
+			return fmt.Sprintf("%s.%02x%02x%02x", entry.typ, avcC[1], avcC[2], avcC[3])
+		}
+	}
+	return entry.typ
+}
+
+// trackSize 优先使用 tkhd 中的显示尺寸，缺失时使用样本描述中的编码尺寸
+func trackSize(trak []byte, entry box) (int, int) {
+	if tkhd, ok := find(trak, "tkhd"); ok && len(tkhd) >= 4 {
+		// 宽高为 16.16 定点数，位于 tkhd 末尾
+		offset := 76
+		if tkhd[0] == 1 {
+			offset = 88
+		}
+		if len(tkhd) >= offset+8 {
+			w := int(binary.BigEndian.Uint32(tkhd[offset:]) >> 16)
+			h := int(binary.BigEndian.Uint32(tkhd[offset+4:]) >> 16)
+			if w > 0 && h > 0 {
+				return w, h
+			}
+		}
+	}
+	if len(entry.data) >= 28 {
+		return int(binary.BigEndian.Uint16(entry.data[24:])), int(binary.BigEndian.Uint16(entry.data[26:]))
+	}
+	return 0, 0
+}
@@ -0,0 +1,106 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func mkbox(typ string, parts ...[]byte) []byte {
+	body := bytes.Join(parts, nil)
+	b := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(b, uint32(8+len(body)))
+	copy(b[4:], typ)
+	return append(b, body...)
+}
+
+func u32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+func mvhd(timescale, duration uint32) []byte {
+	return mkbox("mvhd", make([]byte, 12), u32(timescale), u32(duration), make([]byte, 80))
+}
+
+func trak(handler string, entry []byte, width, height uint32) []byte {
+	tkhd := mkbox("tkhd", make([]byte, 76), u32(width<<16), u32(height<<16))
+	mdhd := mkbox("mdhd", make([]byte, 12), u32(1000), u32(90500), make([]byte, 4))
+	hdlr := mkbox("hdlr", make([]byte, 8), []byte(handler), make([]byte, 13))
+	stsd := mkbox("stsd", make([]byte, 4), u32(1), entry)
+	return mkbox("trak", tkhd, mkbox("mdia", mdhd, hdlr, mkbox("minf", mkbox("stbl", stsd))))
+}
+
+func avc1(width, height uint16) []byte {
+	fixed := make([]byte, visualSampleEntryLen)
+	binary.BigEndian.PutUint16(fixed[24:], width)
+	binary.BigEndian.PutUint16(fixed[26:], height)
+	return mkbox("avc1", fixed, mkbox("avcC", []byte{1, 0x64, 0x00, 0x1f, 0xff}))
+}
+
+func sampleMoov(movieDuration uint32) []byte {
+	return mkbox("moov",
+		mvhd(600, movieDuration),
+		trak("vide", avc1(1920, 1080), 1280, 720),
+		trak("soun", mkbox("mp4a", make([]byte, 28)), 0, 0),
+	)
+}
+
+func TestParse(t *testing.T) {
+	ftyp := mkbox("ftyp", []byte("isom"), u32(512), []byte("isommp41"))
+	mdat := mkbox("mdat", make([]byte, 4096))
+
+	tests := []struct {
+		name      string
+		file      []byte
+		duration  float64
+		fastStart bool
+	}{
+		{"fast-start", bytes.Join([][]byte{ftyp, sampleMoov(54300), mdat}, nil), 90.5, true},
+		{"moov 在末尾", bytes.Join([][]byte{ftyp, mdat, sampleMoov(54300)}, nil), 90.5, false},
+		{"mvhd 时长为 0 时使用轨道时长", bytes.Join([][]byte{ftyp, sampleMoov(0), mdat}, nil), 90.5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Parse(bytes.NewReader(tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := Info{
+				Duration:   tt.duration,
+				Width:      1280,
+				Height:     720,
+				VideoCodec: "avc1.64001f",
+				AudioCodec: "mp4a",
+				FastStart:  tt.fastStart,
+			}
+			if *info != want {
+				t.Fatalf("Parse() = %+v, want %+v", *info, want)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	ftyp := mkbox("ftyp", []byte("isom"), u32(512))
+	truncated := sampleMoov(600)
+
+	tests := []struct {
+		name string
+		file []byte
+		want error
+	}{
+		{"缺少 moov", bytes.Join([][]byte{ftyp, mkbox("mdat", make([]byte, 16))}, nil), ErrNoMoov},
+		{"moov 不完整", append(ftyp, truncated[:len(truncated)-10]...), ErrNotMP4},
+		{"非 MP4", []byte("\x00\x01\x02\x03\x04\x05\x06\x07 not an mp4 file"), ErrNotMP4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(bytes.NewReader(tt.file)); !errors.Is(err, tt.want) {
+				t.Fatalf("Parse() err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+// Package storage 定义文件存储后端。业务代码只依赖 Storage 接口，按对象键读写文件，
+// 由具体实现决定文件保存的位置与对外访问地址。
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+var (
+	ErrInvalidKey = errors.New("无效的文件路径")
+	ErrNotFound   = errors.New("文件不存在")
+)
+
+// Storage 文件存储后端
+type Storage interface {
+	// Put 写入文件，已存在时覆盖
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Open 读取文件，不存在时返回 ErrNotFound
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 删除文件，不存在时不报错
+	Delete(ctx context.Context, key string) error
+	// URL 返回文件的访问地址
+	URL(key string) string
+}
+
+// CleanKey 规范化对象键，拒绝空路径、绝对路径与跳出根目录的路径
+func CleanKey(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return "", ErrInvalidKey
+	}
+	cleaned := path.Clean(key)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", ErrInvalidKey
+	}
+	return cleaned, nil
+}
@@ -0,0 +1,93 @@
+// Package subtitle 处理视频字幕文件，将 SRT 转换为浏览器与小程序播放器支持的 WebVTT。
+package subtitle
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrInvalidSubtitle = errors.New("字幕文件格式无效")
+
+// SRT 时间轴，毫秒分隔符允许逗号或点
+var srtTiming = regexp.MustCompile(`^(\d{1,2}:\d{2}:\d{2})[,.](\d{3})\s*-->\s*(\d{1,2}:\d{2}:\d{2})[,.](\d{3})`)
+
+// VTT 时间轴，小时可省略，其后可带 cue 设置
+var vttTiming = regexp.MustCompile(`^(?:\d+:)?\d{2}:\d{2}\.\d{3}\s+-->\s+(?:\d+:)?\d{2}:\d{2}\.\d{3}`)
+
+// ToVTT 将 SRT 或 WebVTT 字幕转换为规范的 WebVTT：去除 BOM，统一换行符。
+// 字幕须为 UTF-8 编码。
+func ToVTT(data []byte) ([]byte, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("%w: 请使用 UTF-8 编码", ErrInvalidSubtitle)
+	}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	if strings.HasPrefix(text, "WEBVTT") {
+		return checkVTT(text)
+	}
+	return srtToVTT(text)
+}
+
+// checkVTT 校验 WebVTT 至少包含一条字幕
+func checkVTT(text string) ([]byte, error) {
+	header, _, _ := strings.Cut(text, "\n")
+	if header != "WEBVTT" && !strings.HasPrefix(header, "WEBVTT ") && !strings.HasPrefix(header, "WEBVTT\t") {
+		return nil, ErrInvalidSubtitle
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if vttTiming.MatchString(line) {
+			return []byte(text), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: 没有字幕内容", ErrInvalidSubtitle)
+}
+
+// srtToVTT 逐条转换 SRT 字幕：去掉序号，时间轴的毫秒分隔符改为点
+func srtToVTT(text string) ([]byte, error) {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+
+	cues := 0
+	for _, block := range strings.Split(strings.TrimSpace(text), "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		if len(lines) == 0 || strings.TrimSpace(lines[0]) == "" {
+			continue
+		}
+		// 序号行可省略
+		if !srtTiming.MatchString(lines[0]) {
+			lines = lines[1:]
+		}
+		if len(lines) == 0 {
+			return nil, fmt.Errorf("%w: 第 %d 条字幕缺少时间轴", ErrInvalidSubtitle, cues+1)
+		}
+		m := srtTiming.FindStringSubmatch(strings.TrimSpace(lines[0]))
+		if m == nil {
+			return nil, fmt.Errorf("%w: 第 %d 条字幕时间轴无效", ErrInvalidSubtitle, cues+1)
+		}
+
+		fmt.Fprintf(&b, "\n%s.%s --> %s.%s\n", padHour(m[1]), m[2], padHour(m[3]), m[4])
+		for _, line := range lines[1:] {
+			// 空行会提前结束 WebVTT 字幕，"-->" 会被误认为时间轴
+			b.WriteString(strings.ReplaceAll(line, "-->", "->") + "\n")
+		}
+		cues++
+	}
+	if cues == 0 {
+		return nil, fmt.Errorf("%w: 没有字幕内容", ErrInvalidSubtitle)
+	}
+	return []byte(b.String()), nil
+}
+
+// padHour 将单位数的小时补齐为两位
+func padHour(t string) string {
+	if len(t) == len("0:00:00") {
+		return "0" + t
+	}
+	return t
+}
@@ -0,0 +1,41 @@
+package subtitle
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestToVTT(t *testing.T) {
+	srt := "\xef\xbb\xbf1\r\n00:00:01,000 --> 00:00:04,500\r\n欢迎参加新员工培训\r\n\r\n" +
+		"2\r\n0:01:02,250 --> 0:01:05,000\r\n第一行\r\n第二行 --> 箭头\r\n\r\n\r\n"
+	want := "WEBVTT\n\n" +
+		"00:00:01.000 --> 00:00:04.500\n欢迎参加新员工培训\n\n" +
+		"00:01:02.250 --> 00:01:05.000\n第一行\n第二行 -> 箭头\n"
+
+	got, err := ToVTT([]byte(srt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Fatalf("ToVTT() =\n%q\nwant\n%q", got, want)
+	}
+
+	vtt := "WEBVTT\n\n00:01.000 --> 00:02.000 align:start\nhello\n"
+	if got, err := ToVTT([]byte(vtt)); err != nil || string(got) != vtt {
+		t.Fatalf("ToVTT(vtt) = %q, %v", got, err)
+	}
+}
+
+func TestToVTTInvalid(t *testing.T) {
+	for name, data := range map[string]string{
+		"空文件":      "",
+		"时间轴无效":    "1\n00:00:01 --> 00:00:02\nhello\n",
+		"非 UTF-8":  "1\n00:00:01,000 --> 00:00:02,000\n\xc4\xe3\xba\xc3\n",
+		"VTT 无字幕":  "WEBVTT\n\nNOTE nothing here\n",
+		"VTT 头部无效": "WEBVTTX\n\n00:01.000 --> 00:02.000\nhi\n",
+	} {
+		if _, err := ToVTT([]byte(data)); !errors.Is(err, ErrInvalidSubtitle) {
+			t.Errorf("%s: err = %v, want ErrInvalidSubtitle", name, err)
+		}
+	}
+}
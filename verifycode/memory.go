@@ -0,0 +1,81 @@
+package verifycode
+
+import (
+	"context"
+	"crypto/subtle"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	code      string // 已使用或作废后为空
+	sentAt    time.Time
+	expiresAt time.Time
+	attempts  int
+}
+
+// MemoryStore 进程内的验证码存储，适用于单实例部署
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+	now     func() time.Time
+}
+
+// NewMemoryStore 创建进程内验证码存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*entry), now: time.Now}
+}
+
+func (m *MemoryStore) Save(_ context.Context, phone, code string, ttl, cooldown time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	if e, ok := m.entries[phone]; ok && now.Before(e.expiresAt) && now.Before(e.sentAt.Add(cooldown)) {
+		return ErrCooldown
+	}
+	m.entries[phone] = &entry{code: code, sentAt: now, expiresAt: now.Add(ttl)}
+	return nil
+}
+
+func (m *MemoryStore) Verify(_ context.Context, phone, code string, maxAttempts int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[phone]
+	if !ok || e.code == "" {
+		return ErrInvalidCode
+	}
+	if !m.now().Before(e.expiresAt) {
+		delete(m.entries, phone)
+		return ErrInvalidCode
+	}
+	if subtle.ConstantTimeCompare([]byte(e.code), []byte(code)) != 1 {
+		e.attempts++
+		if e.attempts >= maxAttempts {
+			e.code = ""
+			return ErrTooManyAttempts
+		}
+		return ErrInvalidCode
+	}
+	// 保留记录直到过期，发送间隔仍然生效
+	e.code = ""
+	return nil
+}
+
+func (m *MemoryStore) Delete(_ context.Context, phone string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, phone)
+	return nil
+}
+
+func (m *MemoryStore) DeleteExpired(context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	for phone, e := range m.entries {
+		if !now.Before(e.expiresAt) {
+			delete(m.entries, phone)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package verifycode
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// LogSender 将验证码写入日志而不发送短信，仅用于开发环境
+type LogSender struct{}
+
+func (LogSender) Send(_ context.Context, phone, code string) error {
+	log.Infof("发送验证码到 %s: %s", phone, code)
+	return nil
+}
+
+// FileSender 将验证码追加写入文件而不发送短信，供开发环境的前端或测试脚本读取
+type FileSender struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+func (s *FileSender) Send(_ context.Context, phone, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(f, "%s\t%s\t%s\n", time.Now().Format(time.RFC3339), phone, code); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
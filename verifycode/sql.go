@@ -0,0 +1,110 @@
+package verifycode
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SMSCode 数据库中保存的验证码
+type SMSCode struct {
+	Phone     string    `gorm:"type:varchar(20);primaryKey"`
+	Code      string    `gorm:"type:varchar(10)"` // 已使用或作废后为空
+	SentAt    time.Time `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	Attempts  int       `gorm:"not null;default:0"`
+}
+
+func (SMSCode) TableName() string {
+	return "sms_codes"
+}
+
+// SQLStore 数据库中的验证码存储，多实例部署时共享
+type SQLStore struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+// NewSQLStore 创建数据库验证码存储，并创建所需的表
+func NewSQLStore(db *gorm.DB) (*SQLStore, error) {
+	if err := db.AutoMigrate(&SMSCode{}); err != nil {
+		return nil, err
+	}
+	return &SQLStore{db: db, now: time.Now}, nil
+}
+
+func (s *SQLStore) Save(ctx context.Context, phone, code string, ttl, cooldown time.Duration) error {
+	now := s.now()
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var old SMSCode
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("phone = ?", phone).First(&old).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return tx.Create(&SMSCode{Phone: phone, Code: code, SentAt: now, ExpiresAt: now.Add(ttl)}).Error
+		case err != nil:
+			return err
+		case now.Before(old.ExpiresAt) && now.Before(old.SentAt.Add(cooldown)):
+			return ErrCooldown
+		}
+		return tx.Model(&old).Updates(map[string]any{
+			"code": code, "sent_at": now, "expires_at": now.Add(ttl), "attempts": 0,
+		}).Error
+	})
+	if isDuplicateKey(err) {
+		// 同一手机号并发发送，另一个请求已写入
+		return ErrCooldown
+	}
+	return err
+}
+
+func (s *SQLStore) Verify(ctx context.Context, phone, code string, maxAttempts int) error {
+	// 校验失败时也要提交错误次数，结果通过 result 返回而不是回滚事务
+	var result error
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var row SMSCode
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("phone = ? AND code <> '' AND expires_at > ?", phone, s.now()).First(&row).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			result = ErrInvalidCode
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if subtle.ConstantTimeCompare([]byte(row.Code), []byte(code)) == 1 {
+			// 保留记录直到过期，发送间隔仍然生效
+			return tx.Model(&row).Update("code", "").Error
+		}
+		updates := map[string]any{"attempts": row.Attempts + 1}
+		result = ErrInvalidCode
+		if row.Attempts+1 >= maxAttempts {
+			updates["code"] = ""
+			result = ErrTooManyAttempts
+		}
+		return tx.Model(&row).Updates(updates).Error
+	})
+	if err != nil {
+		return err
+	}
+	return result
+}
+
+func (s *SQLStore) Delete(ctx context.Context, phone string) error {
+	return s.db.WithContext(ctx).Where("phone = ?", phone).Delete(&SMSCode{}).Error
+}
+
+func (s *SQLStore) DeleteExpired(ctx context.Context) error {
+	return s.db.WithContext(ctx).Where("expires_at <= ?", s.now()).Delete(&SMSCode{}).Error
+}
+
+func isDuplicateKey(err error) bool {
+	return err != nil && (errors.Is(err, gorm.ErrDuplicatedKey) ||
+		strings.Contains(err.Error(), "UNIQUE constraint failed") ||
+		strings.Contains(err.Error(), "Duplicate entry"))
+}
@@ -0,0 +1,103 @@
+// Package verifycode 实现短信验证码的生成、发送与校验。
+// 验证码保存在 Store 中，由 SMSSender 发送；Store 负责在并发下原子地处理发送间隔与错误次数。
+package verifycode
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+var (
+	ErrCooldown        = errors.New("验证码发送过于频繁，请稍后再试")
+	ErrInvalidCode     = errors.New("验证码错误或已过期")
+	ErrTooManyAttempts = errors.New("验证码错误次数过多，请重新获取")
+)
+
+// Store 验证码存储，每个手机号只保留最近一次发送的验证码
+type Store interface {
+	// Save 保存验证码并覆盖旧验证码；距上次发送不足 cooldown 且旧验证码未过期时返回 ErrCooldown
+	Save(ctx context.Context, phone, code string, ttl, cooldown time.Duration) error
+	// Verify 校验验证码，成功后验证码作废；连续错误达到 maxAttempts 次后验证码作废并返回 ErrTooManyAttempts
+	Verify(ctx context.Context, phone, code string, maxAttempts int) error
+	// Delete 删除手机号的验证码，用于发送失败后允许立即重发
+	Delete(ctx context.Context, phone string) error
+	// DeleteExpired 清理已过期的验证码
+	DeleteExpired(ctx context.Context) error
+}
+
+// SMSSender 短信发送渠道
+type SMSSender interface {
+	Send(ctx context.Context, phone, code string) error
+}
+
+// Options 验证码规则
+type Options struct {
+	Length      int           // 验证码位数
+	TTL         time.Duration // 有效期
+	Cooldown    time.Duration // 同一手机号两次发送的最小间隔
+	MaxAttempts int           // 允许的错误次数
+}
+
+// Service 验证码服务
+type Service struct {
+	store  Store
+	sender SMSSender
+	opts   Options
+}
+
+// New 创建验证码服务
+func New(store Store, sender SMSSender, opts Options) *Service {
+	if opts.Length <= 0 {
+		opts.Length = 6
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	return &Service{store: store, sender: sender, opts: opts}
+}
+
+// Send 生成验证码并发送到手机号
+func (s *Service) Send(ctx context.Context, phone string) error {
+	code, err := NewCode(s.opts.Length)
+	if err != nil {
+		return err
+	}
+	if err := s.store.Save(ctx, phone, code, s.opts.TTL, s.opts.Cooldown); err != nil {
+		return err
+	}
+	if err := s.sender.Send(ctx, phone, code); err != nil {
+		// 未发送成功的验证码不占用发送间隔
+		if delErr := s.store.Delete(ctx, phone); delErr != nil {
+			return errors.Join(err, delErr)
+		}
+		return fmt.Errorf("发送短信失败: %w", err)
+	}
+	return nil
+}
+
+// Verify 校验手机号收到的验证码
+func (s *Service) Verify(ctx context.Context, phone, code string) error {
+	if code == "" {
+		return ErrInvalidCode
+	}
+	return s.store.Verify(ctx, phone, code, s.opts.MaxAttempts)
+}
+
+// DeleteExpired 清理已过期的验证码
+func (s *Service) DeleteExpired(ctx context.Context) error {
+	return s.store.DeleteExpired(ctx)
+}
+
+// NewCode 生成指定位数的随机数字验证码
+func NewCode(length int) (string, error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(length)), nil)
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", length, n), nil
+}
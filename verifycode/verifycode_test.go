@@ -0,0 +1,107 @@
+package verifycode
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func TestStores(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlStore, err := NewSQLStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	memStore := NewMemoryStore()
+
+	for name, tc := range map[string]struct {
+		store Store
+		clock *func() time.Time
+	}{
+		"memory": {memStore, &memStore.now},
+		"sql":    {sqlStore, &sqlStore.now},
+	} {
+		t.Run(name, func(t *testing.T) {
+			clock := &fakeClock{t: time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)}
+			*tc.clock = clock.now
+			ctx := context.Background()
+			s := tc.store
+			const ttl, cooldown = 5 * time.Minute, time.Minute
+
+			if err := s.Save(ctx, "138", "111111", ttl, cooldown); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Save(ctx, "138", "222222", ttl, cooldown); !errors.Is(err, ErrCooldown) {
+				t.Fatalf("发送间隔内重发 err = %v", err)
+			}
+			if err := s.Verify(ctx, "138", "000000", 2); !errors.Is(err, ErrInvalidCode) {
+				t.Fatalf("错误验证码 err = %v", err)
+			}
+			if err := s.Verify(ctx, "138", "111111", 2); err != nil {
+				t.Fatalf("正确验证码 err = %v", err)
+			}
+			if err := s.Verify(ctx, "138", "111111", 2); !errors.Is(err, ErrInvalidCode) {
+				t.Fatalf("重复使用 err = %v", err)
+			}
+
+			clock.t = clock.t.Add(cooldown)
+			if err := s.Save(ctx, "138", "333333", ttl, cooldown); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Verify(ctx, "138", "000000", 2); !errors.Is(err, ErrInvalidCode) {
+				t.Fatalf("第一次错误 err = %v", err)
+			}
+			if err := s.Verify(ctx, "138", "000000", 2); !errors.Is(err, ErrTooManyAttempts) {
+				t.Fatalf("第二次错误 err = %v", err)
+			}
+			if err := s.Verify(ctx, "138", "333333", 2); !errors.Is(err, ErrInvalidCode) {
+				t.Fatalf("作废后 err = %v", err)
+			}
+
+			clock.t = clock.t.Add(cooldown)
+			if err := s.Save(ctx, "138", "444444", ttl, cooldown); err != nil {
+				t.Fatal(err)
+			}
+			clock.t = clock.t.Add(ttl)
+			if err := s.Verify(ctx, "138", "444444", 2); !errors.Is(err, ErrInvalidCode) {
+				t.Fatalf("过期后 err = %v", err)
+			}
+			if err := s.DeleteExpired(ctx); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	if len(memStore.entries) != 0 {
+		t.Fatalf("过期验证码未清理: %d", len(memStore.entries))
+	}
+	var n int64
+	db.Model(&SMSCode{}).Count(&n)
+	if n != 0 {
+		t.Fatalf("过期验证码未清理: %d", n)
+	}
+}
+
+func TestNewCode(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		code, err := NewCode(6)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(code) != 6 {
+			t.Fatalf("code = %q", code)
+		}
+	}
+}
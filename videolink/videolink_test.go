@@ -0,0 +1,42 @@
+package videolink
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	s := NewSigner([]byte("secret"), 10*time.Minute)
+	s.now = func() time.Time { return now }
+
+	q, expires := s.Sign(7, 42)
+	if !expires.Equal(now.Add(10 * time.Minute)) {
+		t.Fatalf("expires = %v", expires)
+	}
+	if uid, err := s.Verify(7, q); err != nil || uid != 42 {
+		t.Fatalf("Verify() = %d, %v", uid, err)
+	}
+
+	// 链接不能用于其他视频，也不能改成其他用户
+	if _, err := s.Verify(8, q); !errors.Is(err, ErrInvalidLink) {
+		t.Fatalf("其他视频 err = %v", err)
+	}
+	forged := q
+	forged.Set("uid", "43")
+	if _, err := s.Verify(7, forged); !errors.Is(err, ErrInvalidLink) {
+		t.Fatalf("篡改用户 err = %v", err)
+	}
+
+	q, _ = s.Sign(7, 42)
+	other := NewSigner([]byte("other"), 10*time.Minute)
+	if _, err := other.Verify(7, q); !errors.Is(err, ErrInvalidLink) {
+		t.Fatalf("其他密钥 err = %v", err)
+	}
+
+	now = now.Add(11 * time.Minute)
+	if _, err := s.Verify(7, q); !errors.Is(err, ErrLinkExpired) {
+		t.Fatalf("过期 err = %v", err)
+	}
+}
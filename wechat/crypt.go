@@ -0,0 +1,91 @@
+package wechat
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+var (
+	ErrDecrypt   = errors.New("解密数据失败，请重新登录后再试")
+	ErrWatermark = errors.New("数据不属于当前小程序")
+)
+
+// PhoneInfo getPhoneNumber 返回的加密数据解密后的内容
+type PhoneInfo struct {
+	PhoneNumber     string    `json:"phoneNumber"`     // 带区号的手机号，境外手机号会带 + 号
+	PurePhoneNumber string    `json:"purePhoneNumber"` // 不带区号的手机号
+	CountryCode     string    `json:"countryCode"`
+	Watermark       Watermark `json:"watermark"`
+}
+
+// Watermark 加密数据中的水印，用于确认数据由哪个小程序获取
+type Watermark struct {
+	AppID     string `json:"appid"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// DecryptPhone 用 session_key 解密 getPhoneNumber 返回的 encryptedData，并校验水印中的 AppID
+func (c *Client) DecryptPhone(sessionKey, encryptedData, iv string) (*PhoneInfo, error) {
+	plain, err := Decrypt(sessionKey, encryptedData, iv)
+	if err != nil {
+		return nil, err
+	}
+	var info PhoneInfo
+	if err := json.Unmarshal(plain, &info); err != nil {
+		return nil, ErrDecrypt
+	}
+	if info.Watermark.AppID != c.appID {
+		return nil, ErrWatermark
+	}
+	if info.PurePhoneNumber == "" {
+		return nil, ErrDecrypt
+	}
+	return &info, nil
+}
+
+// Decrypt 解密开放数据，算法为 AES-128-CBC，session_key、encryptedData 与 iv 均为 base64 编码
+func Decrypt(sessionKey, encryptedData, iv string) ([]byte, error) {
+	key, err1 := base64.StdEncoding.DecodeString(sessionKey)
+	data, err2 := base64.StdEncoding.DecodeString(encryptedData)
+	ivb, err3 := base64.StdEncoding.DecodeString(iv)
+	if err := errors.Join(err1, err2, err3); err != nil {
+		return nil, ErrDecrypt
+	}
+	if len(key) != 16 || len(ivb) != aes.BlockSize || len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, ErrDecrypt
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, ivb).CryptBlocks(plain, data)
+
+	// 去除 PKCS#7 填充；session_key 不匹配时填充几乎必然无效
+	n := int(plain[len(plain)-1])
+	if n == 0 || n > aes.BlockSize || !bytes.Equal(plain[len(plain)-n:], bytes.Repeat([]byte{byte(n)}, n)) {
+		return nil, ErrDecrypt
+	}
+	return plain[:len(plain)-n], nil
+}
+
+// Encrypt 与 Decrypt 对应的加密，供模拟服务与测试生成加密数据
+func Encrypt(sessionKey string, plain, iv []byte) (string, error) {
+	key, err := base64.StdEncoding.DecodeString(sessionKey)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	n := aes.BlockSize - len(plain)%aes.BlockSize
+	data := append(append([]byte{}, plain...), bytes.Repeat([]byte{byte(n)}, n)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+	return base64.StdEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,112 @@
+// Package wechat 封装小程序服务端接口。
+package wechat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL 微信接口地址
+const DefaultBaseURL = "https://api.weixin.qq.com"
+
+var ErrInvalidCode = errors.New("登录凭证无效或已使用")
+
+// 微信接口错误码
+const (
+	codeInvalidCode = 40029 // js_code 无效
+	codeUsedCode    = 40163 // js_code 已被使用
+)
+
+// APIError 微信接口返回的错误
+type APIError struct {
+	Code int
+	Msg  string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("微信接口错误 %d: %s", e.Code, e.Msg)
+}
+
+// Session code2session 返回的登录会话
+type Session struct {
+	OpenID     string `json:"openid"`
+	UnionID    string `json:"unionid,omitempty"`
+	SessionKey string `json:"session_key"`
+}
+
+// Client 小程序服务端接口客户端
+type Client struct {
+	appID   string
+	secret  string
+	baseURL string
+	http    *http.Client
+}
+
+// NewClient 创建客户端，baseURL 为空时使用微信接口地址，测试时可指向本地模拟服务
+func NewClient(appID, secret, baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		appID:   appID,
+		secret:  secret,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// AppID 返回小程序 AppID
+func (c *Client) AppID() string {
+	return c.appID
+}
+
+// Code2Session 用 wx.login 得到的 code 换取 openid、unionid 与 session_key
+func (c *Client) Code2Session(ctx context.Context, code string) (*Session, error) {
+	q := url.Values{}
+	q.Set("appid", c.appID)
+	q.Set("secret", c.secret)
+	q.Set("js_code", code)
+	q.Set("grant_type", "authorization_code")
+
+	var resp struct {
+		Session
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := c.get(ctx, "/sns/jscode2session?"+q.Encode(), &resp); err != nil {
+		return nil, err
+	}
+	switch resp.ErrCode {
+	case 0:
+	case codeInvalidCode, codeUsedCode:
+		return nil, ErrInvalidCode
+	default:
+		return nil, &APIError{Code: resp.ErrCode, Msg: resp.ErrMsg}
+	}
+	if resp.OpenID == "" || resp.SessionKey == "" {
+		return nil, errors.New("微信接口未返回 openid")
+	}
+	return &resp.Session, nil
+}
+
+func (c *Client) get(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("微信接口请求失败: %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,75 @@
+package wechat_test
+
+import (
+	"context"
+	"errors"
+	"mio/gin-example/wechat"
+	"mio/gin-example/wechat/wechattest"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCode2Session(t *testing.T) {
+	fake := wechattest.NewServer("appid", "secret")
+	fake.AddCode("code-1", wechat.Session{OpenID: "o1", UnionID: "u1", SessionKey: "k1"})
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	client := wechat.NewClient("appid", "secret", srv.URL)
+	ctx := context.Background()
+
+	session, err := client.Code2Session(ctx, "code-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *session != (wechat.Session{OpenID: "o1", UnionID: "u1", SessionKey: "k1"}) {
+		t.Fatalf("session = %+v", session)
+	}
+
+	// code 只能使用一次
+	if _, err := client.Code2Session(ctx, "code-1"); !errors.Is(err, wechat.ErrInvalidCode) {
+		t.Fatalf("重复使用 err = %v", err)
+	}
+	if _, err := client.Code2Session(ctx, "unknown"); !errors.Is(err, wechat.ErrInvalidCode) {
+		t.Fatalf("未知 code err = %v", err)
+	}
+
+	session, err = client.Code2Session(ctx, "mock_alice")
+	if err != nil || session.OpenID != "mock-openid-alice" {
+		t.Fatalf("mock code = %+v, %v", session, err)
+	}
+
+	var apiErr *wechat.APIError
+	_, err = wechat.NewClient("appid", "wrong", srv.URL).Code2Session(ctx, "mock_bob")
+	if !errors.As(err, &apiErr) || apiErr.Code != 40125 {
+		t.Fatalf("错误的 secret err = %v", err)
+	}
+}
+
+func TestDecryptPhone(t *testing.T) {
+	fake := wechattest.NewServer("appid", "secret")
+	sessionKey := wechattest.MockSession("mock_alice").SessionKey
+	data, iv, err := fake.EncryptPhone(sessionKey, "13800138000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := wechat.NewClient("appid", "secret", "").DecryptPhone(sessionKey, data, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.PurePhoneNumber != "13800138000" || info.CountryCode != "86" {
+		t.Fatalf("info = %+v", info)
+	}
+
+	if _, err := wechat.NewClient("other", "secret", "").DecryptPhone(sessionKey, data, iv); !errors.Is(err, wechat.ErrWatermark) {
+		t.Fatalf("其他小程序 err = %v", err)
+	}
+	otherKey := wechattest.MockSession("mock_bob").SessionKey
+	if _, err := wechat.NewClient("appid", "secret", "").DecryptPhone(otherKey, data, iv); !errors.Is(err, wechat.ErrDecrypt) {
+		t.Fatalf("session_key 不匹配 err = %v", err)
+	}
+	if _, err := wechat.NewClient("appid", "secret", "").DecryptPhone(sessionKey, "not base64", iv); !errors.Is(err, wechat.ErrDecrypt) {
+		t.Fatalf("格式错误 err = %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+// Package wechattest 提供本地运行的微信接口模拟服务，用于离线开发与测试。
+package wechattest
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"mio/gin-example/wechat"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MockCodePrefix 以该前缀开头的 code 无需预先登记，模拟服务按 code 生成固定的会话，
+// 如 mock_alice 对应 openid mock-openid-alice
+const MockCodePrefix = "mock_"
+
+// Server 模拟 code2session 接口。每个 code 只能使用一次，与微信接口一致。
+type Server struct {
+	AppID  string
+	Secret string
+
+	mu    sync.Mutex
+	codes map[string]wechat.Session
+	used  map[string]bool
+}
+
+// NewServer 创建模拟服务
+func NewServer(appID, secret string) *Server {
+	return &Server{
+		AppID:  appID,
+		Secret: secret,
+		codes:  make(map[string]wechat.Session),
+		used:   make(map[string]bool),
+	}
+}
+
+// AddCode 登记 code 对应的会话
+func (s *Server) AddCode(code string, session wechat.Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.codes[code] = session
+}
+
+// MockSession 返回 MockCodePrefix 开头的 code 对应的会话
+func MockSession(code string) wechat.Session {
+	name := strings.TrimPrefix(code, MockCodePrefix)
+	sum := sha256.Sum256([]byte(code))
+	return wechat.Session{
+		OpenID:     "mock-openid-" + name,
+		SessionKey: base64.StdEncoding.EncodeToString(sum[:16]),
+	}
+}
+
+// EncryptPhone 模拟 getPhoneNumber，用 session_key 加密手机号，返回 encryptedData 与 iv
+func (s *Server) EncryptPhone(sessionKey, phone string) (encryptedData, iv string, err error) {
+	plain, err := json.Marshal(wechat.PhoneInfo{
+		PhoneNumber:     phone,
+		PurePhoneNumber: phone,
+		CountryCode:     "86",
+		Watermark:       wechat.Watermark{AppID: s.AppID, Timestamp: time.Now().Unix()},
+	})
+	if err != nil {
+		return "", "", err
+	}
+	ivb := make([]byte, 16)
+	rand.Read(ivb)
+	encryptedData, err = wechat.Encrypt(sessionKey, plain, ivb)
+	return encryptedData, base64.StdEncoding.EncodeToString(ivb), err
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/sns/jscode2session":
+		s.code2Session(w, r)
+	case "/mock/phonenumber":
+		s.phoneNumber(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// phoneNumber 为 mock_ 开头的 code 对应的会话生成手机号加密数据，
+// 供本地调试时代替小程序 getPhoneNumber 的返回值
+func (s *Server) phoneNumber(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	code, phone := q.Get("code"), q.Get("phone")
+	if !strings.HasPrefix(code, MockCodePrefix) || phone == "" {
+		http.Error(w, "需要 mock_ 开头的 code 与 phone 参数", http.StatusBadRequest)
+		return
+	}
+	data, iv, err := s.EncryptPhone(MockSession(code).SessionKey, phone)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"encryptedData": data, "iv": iv})
+}
+
+func (s *Server) code2Session(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	switch {
+	case q.Get("appid") != s.AppID:
+		writeError(w, 40013, "invalid appid")
+		return
+	case q.Get("secret") != s.Secret:
+		writeError(w, 40125, "invalid appsecret")
+		return
+	case q.Get("grant_type") != "authorization_code":
+		writeError(w, 40002, "invalid grant_type")
+		return
+	}
+
+	code := q.Get("js_code")
+	s.mu.Lock()
+	session, ok := s.codes[code]
+	if !ok && strings.HasPrefix(code, MockCodePrefix) && len(code) > len(MockCodePrefix) {
+		session, ok = MockSession(code), true
+	}
+	used := s.used[code]
+	if ok && !used {
+		s.used[code] = true
+	}
+	s.mu.Unlock()
+
+	switch {
+	case !ok:
+		writeError(w, 40029, "invalid code")
+	case used:
+		writeError(w, 40163, "code been used")
+	default:
+		json.NewEncoder(w).Encode(session)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	json.NewEncoder(w).Encode(map[string]any{"errcode": code, "errmsg": msg})
+}